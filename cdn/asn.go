@@ -0,0 +1,81 @@
+package cdn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ASNResult is the organization/AS information for a single IP, as
+// reported by a whois-style ASN lookup service.
+type ASNResult struct {
+	ASN string
+	Org string
+}
+
+// ASNLookup resolves an IP to its announcing ASN and organization. It
+// exists mainly so Detect can be tested/driven without a live network
+// lookup.
+type ASNLookup interface {
+	Lookup(ctx context.Context, ip net.IP) (ASNResult, error)
+}
+
+// cymruWhois looks up ASN ownership via Team Cymru's whois service
+// (whois.cymru.com:43), a plain-text protocol requiring no API key and
+// no bundled database.
+type cymruWhois struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewCymruWhois returns an ASNLookup backed by Team Cymru's public
+// whois service.
+func NewCymruWhois(timeout time.Duration) ASNLookup {
+	return &cymruWhois{addr: "whois.cymru.com:43", timeout: timeout}
+}
+
+// Lookup queries Team Cymru's "-v" bulk whois format, which returns one
+// pipe-delimited line per query:
+//
+//	AS | IP | BGP Prefix | CC | Registry | Allocated | AS Name
+func (c *cymruWhois) Lookup(ctx context.Context, ip net.IP) (ASNResult, error) {
+	d := net.Dialer{Timeout: c.timeout}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return ASNResult{}, fmt.Errorf("dialing %s: %w", c.addr, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "-v\n%s\n", ip.String()); err != nil {
+		return ASNResult{}, fmt.Errorf("writing query: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return ASNResult{}, fmt.Errorf("reading response: %w", err)
+	}
+	// lines[0] is the header row; lines[1] is our answer, if present.
+	if len(lines) < 2 {
+		return ASNResult{}, fmt.Errorf("no whois record for %s", ip)
+	}
+
+	fields := strings.Split(lines[1], "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 7 || fields[0] == "NA" {
+		return ASNResult{}, fmt.Errorf("no announcing AS for %s", ip)
+	}
+	return ASNResult{ASN: "AS" + fields[0], Org: fields[6]}, nil
+}
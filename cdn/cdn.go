@@ -0,0 +1,109 @@
+// Package cdn classifies IP addresses against published CDN/cloud
+// provider ranges, replacing the hardcoded cdnProviders map that used
+// to live in package main. Ranges are fetched from each provider's
+// published source on startup when the on-disk cache is older than the
+// refresh interval, and cached on disk; a bundled static snapshot backs
+// -offline use and the first run before anything has been cached.
+package cdn
+
+import (
+	"context"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// Info describes why an IP was classified as belonging to a CDN/cloud
+// provider: which provider, the matching CIDR, and - when available -
+// the announcing ASN and organization name from an ASN lookup.
+type Info struct {
+	Provider string
+	ASN      string
+	Org      string
+	CIDR     string
+}
+
+// Tag renders Info as "Provider[ASN]", or just Provider when no ASN is
+// known. The CLI upper-cases it and prefixes "CDN_MISMATCH_", e.g.
+// "CDN_MISMATCH_CLOUDFLARE[AS13335]".
+func (i Info) Tag() string {
+	if i.ASN == "" {
+		return i.Provider
+	}
+	return i.Provider + "[" + i.ASN + "]"
+}
+
+// Range is a single CIDR announced by a provider.
+type Range struct {
+	Provider string
+	CIDR     string
+	net      *net.IPNet
+}
+
+// Detect returns the first configured range containing any of ips,
+// along with ok=true. If none match and asn is non-nil, it falls back
+// to ASN lookups on each valid IP in turn, returning the first that
+// succeeds, so providers missing from the static/dynamic list are still
+// classified.
+func Detect(ctx context.Context, ranges []Range, asn ASNLookup, ips []string) (Info, bool) {
+	parsed := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if p := net.ParseIP(ip); p != nil {
+			parsed = append(parsed, p)
+		}
+	}
+
+	for _, ip := range parsed {
+		for _, r := range ranges {
+			if r.net != nil && r.net.Contains(ip) {
+				return Info{Provider: r.Provider, CIDR: r.CIDR}, true
+			}
+		}
+	}
+
+	if asn == nil || len(parsed) == 0 {
+		return Info{}, false
+	}
+	for _, ip := range parsed {
+		result, err := asn.Lookup(ctx, ip)
+		if err == nil && result.ASN != "" {
+			return Info{Provider: slugify(result.Org), ASN: result.ASN, Org: result.Org}, true
+		}
+	}
+	return Info{}, false
+}
+
+// slugify reduces an ASN whois "AS Name" (e.g. "GOOGLE, US" or
+// "CLOUDFLARENET - Cloudflare, Inc, US") to a single whitespace- and
+// comma-free token. Info.Provider must stay a single token because
+// Tag()'s output is folded into DNSResult.status, which output/text.go
+// joins with other fields on plain spaces.
+func slugify(name string) string {
+	var b strings.Builder
+	prevUnderscore := false
+	for _, r := range strings.ToUpper(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			prevUnderscore = false
+		} else if !prevUnderscore {
+			b.WriteByte('_')
+			prevUnderscore = true
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// compileRanges parses each CIDR in raw, silently skipping malformed
+// entries (a provider's published list is treated as best-effort, the
+// way the original hardcoded map always was).
+func compileRanges(provider string, raw []string) []Range {
+	out := make([]Range, 0, len(raw))
+	for _, cidr := range raw {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		out = append(out, Range{Provider: provider, CIDR: cidr, net: ipnet})
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package cdn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+type fakeASNLookup struct {
+	result ASNResult
+	err    error
+}
+
+func (f fakeASNLookup) Lookup(ctx context.Context, ip net.IP) (ASNResult, error) {
+	return f.result, f.err
+}
+
+func TestInfoTag(t *testing.T) {
+	tests := []struct {
+		name string
+		info Info
+		want string
+	}{
+		{name: "with ASN", info: Info{Provider: "CLOUDFLARE", ASN: "AS13335"}, want: "CLOUDFLARE[AS13335]"},
+		{name: "without ASN", info: Info{Provider: "cloudflare"}, want: "cloudflare"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.Tag(); got != tt.want {
+				t.Errorf("Tag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectRangeMatch(t *testing.T) {
+	ranges := compileRanges("cloudflare", []string{"1.1.1.0/24"})
+
+	info, ok := Detect(context.Background(), ranges, nil, []string{"1.1.1.1"})
+	if !ok {
+		t.Fatal("Detect() = false, want true")
+	}
+	if info.Provider != "cloudflare" {
+		t.Errorf("Provider = %q, want %q", info.Provider, "cloudflare")
+	}
+	if info.ASN != "" {
+		t.Errorf("ASN = %q, want empty (range match, not ASN fallback)", info.ASN)
+	}
+}
+
+func TestDetectNoMatchWithoutASNLookup(t *testing.T) {
+	ranges := compileRanges("cloudflare", []string{"1.1.1.0/24"})
+
+	if _, ok := Detect(context.Background(), ranges, nil, []string{"8.8.8.8"}); ok {
+		t.Fatal("Detect() = true, want false (no range match, no ASN fallback configured)")
+	}
+}
+
+func TestDetectASNFallbackSanitizesOrg(t *testing.T) {
+	ranges := compileRanges("cloudflare", []string{"1.1.1.0/24"})
+	asn := fakeASNLookup{result: ASNResult{ASN: "AS15169", Org: "GOOGLE, US"}}
+
+	info, ok := Detect(context.Background(), ranges, asn, []string{"8.8.8.8"})
+	if !ok {
+		t.Fatal("Detect() = false, want true")
+	}
+	if info.Provider != "GOOGLE_US" {
+		t.Errorf("Provider = %q, want %q (sanitized, no spaces or commas)", info.Provider, "GOOGLE_US")
+	}
+	if info.Org != "GOOGLE, US" {
+		t.Errorf("Org = %q, want raw %q preserved", info.Org, "GOOGLE, US")
+	}
+	if tag := info.Tag(); tag != "GOOGLE_US[AS15169]" {
+		t.Errorf("Tag() = %q, want single whitespace-free token", tag)
+	}
+}
+
+func TestDetectASNFallbackError(t *testing.T) {
+	ranges := compileRanges("cloudflare", []string{"1.1.1.0/24"})
+	asn := fakeASNLookup{err: errors.New("whois unreachable")}
+
+	if _, ok := Detect(context.Background(), ranges, asn, []string{"8.8.8.8"}); ok {
+		t.Fatal("Detect() = true, want false (ASN lookup failed)")
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"GOOGLE, US", "GOOGLE_US"},
+		{"CLOUDFLARENET - Cloudflare, Inc, US", "CLOUDFLARENET_CLOUDFLARE_INC_US"},
+		{"already-clean", "ALREADY_CLEAN"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
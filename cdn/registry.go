@@ -0,0 +1,187 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheFileName is the name of the on-disk range cache within this
+// tool's user cache directory (e.g. ~/.cache/san-resolver/ranges.json
+// on Linux).
+const cacheFileName = "ranges.json"
+
+// cachedRanges is the on-disk format: provider name to its raw CIDR
+// list, plus when it was fetched so Refresh can decide whether it's
+// stale.
+type cachedRanges struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	Providers map[string][]string `json:"providers"`
+}
+
+// Registry holds the current set of known CDN/cloud CIDR ranges and
+// refreshes them from each provider's published source when Refresh or
+// RefreshIfStale is called (there is no background refresh), persisting the result to disk so a cold start doesn't require a
+// network round trip before the first lookup.
+type Registry struct {
+	mu        sync.RWMutex
+	ranges    []Range
+	fetchedAt time.Time
+
+	httpClient *http.Client
+	cachePath  string
+}
+
+// NewRegistry returns a Registry seeded with the bundled static
+// fallback ranges, or the on-disk cache if one exists and parses; the
+// cache's age is not compared with the bundled snapshot. It never makes
+// a network call; call Refresh to pull current data.
+func NewRegistry() *Registry {
+	r := &Registry{
+		ranges:     compileAll(staticFallback),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	if path, err := cachePath(); err == nil {
+		r.cachePath = path
+		if cached, err := loadCache(path); err == nil {
+			r.mu.Lock()
+			r.ranges = compileAll(cached.Providers)
+			r.fetchedAt = cached.FetchedAt
+			r.mu.Unlock()
+		}
+	}
+	return r
+}
+
+// Ranges returns the currently loaded ranges, safe for concurrent use
+// alongside Refresh.
+func (r *Registry) Ranges() []Range {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ranges
+}
+
+// FetchedAt returns when the current ranges were last refreshed (zero
+// if still running on the bundled static snapshot).
+func (r *Registry) FetchedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fetchedAt
+}
+
+// RefreshIfStale refreshes from each provider's published source if the
+// current data is older than maxAge, or always does nothing when
+// offline is true (-offline flag), leaving whatever was loaded from
+// disk/static fallback in place.
+func (r *Registry) RefreshIfStale(ctx context.Context, maxAge time.Duration, offline bool) error {
+	if offline {
+		return nil
+	}
+	if time.Since(r.FetchedAt()) < maxAge {
+		return nil
+	}
+	return r.Refresh(ctx)
+}
+
+// Refresh fetches every provider's current range list in parallel and,
+// if at least one succeeds, atomically swaps it in and writes it to
+// disk. A provider that fails to fetch keeps contributing its
+// previously known ranges, so a single outage doesn't blind the whole
+// registry.
+func (r *Registry) Refresh(ctx context.Context) error {
+	type fetched struct {
+		provider string
+		cidrs    []string
+		err      error
+	}
+	results := make(chan fetched, len(sources))
+	for _, s := range sources {
+		go func(s source) {
+			cidrs, err := s.fetch(ctx, r.httpClient)
+			results <- fetched{provider: s.provider, cidrs: cidrs, err: err}
+		}(s)
+	}
+
+	providers := make(map[string][]string, len(sources))
+	var firstErr error
+	for i := 0; i < len(sources); i++ {
+		f := <-results
+		if f.err != nil {
+			if firstErr == nil {
+				firstErr = f.err
+			}
+			continue
+		}
+		providers[f.provider] = f.cidrs
+	}
+	if len(providers) == 0 {
+		return fmt.Errorf("refreshing CDN ranges: all providers failed, first error: %w", firstErr)
+	}
+
+	// Providers that failed this round keep their last known ranges
+	// rather than disappearing from the registry outright.
+	r.mu.Lock()
+	existing := map[string][]string{}
+	for _, rg := range r.ranges {
+		existing[rg.Provider] = append(existing[rg.Provider], rg.CIDR)
+	}
+	for provider, cidrs := range existing {
+		if _, ok := providers[provider]; !ok {
+			providers[provider] = cidrs
+		}
+	}
+
+	fetchedAt := time.Now()
+	r.ranges = compileAll(providers)
+	r.fetchedAt = fetchedAt
+	r.mu.Unlock()
+
+	if r.cachePath != "" {
+		_ = saveCache(r.cachePath, cachedRanges{FetchedAt: fetchedAt, Providers: providers})
+	}
+	return firstErr
+}
+
+func compileAll(providers map[string][]string) []Range {
+	var out []Range
+	for provider, cidrs := range providers {
+		out = append(out, compileRanges(provider, cidrs)...)
+	}
+	return out
+}
+
+func cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "san-resolver", cacheFileName), nil
+}
+
+func loadCache(path string) (cachedRanges, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cachedRanges{}, err
+	}
+	var out cachedRanges
+	if err := json.Unmarshal(data, &out); err != nil {
+		return cachedRanges{}, err
+	}
+	return out, nil
+}
+
+func saveCache(path string, c cachedRanges) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
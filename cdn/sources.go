@@ -0,0 +1,219 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// source fetches a provider's currently-published CIDR list.
+type source struct {
+	provider string
+	fetch    func(ctx context.Context, httpClient *http.Client) ([]string, error)
+}
+
+// sources is the list of providers refreshed by Registry.Refresh. Each
+// entry hits that provider's own published list, so ranges stay current
+// without us maintaining them by hand.
+var sources = []source{
+	{provider: "cloudflare", fetch: fetchCloudflare},
+	{provider: "cloudfront", fetch: fetchAWS("CLOUDFRONT")},
+	{provider: "aws_global_accelerator", fetch: fetchAWS("GLOBALACCELERATOR")},
+	{provider: "fastly", fetch: fetchFastly},
+	{provider: "google", fetch: fetchGoogle},
+	{provider: "azure", fetch: fetchAzure},
+	{provider: "github", fetch: fetchGitHub},
+}
+
+func get(ctx context.Context, httpClient *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchCloudflare merges Cloudflare's published IPv4 and IPv6 lists,
+// each a plain newline-separated list of CIDRs.
+func fetchCloudflare(ctx context.Context, httpClient *http.Client) ([]string, error) {
+	var out []string
+	for _, url := range []string{"https://www.cloudflare.com/ips-v4", "https://www.cloudflare.com/ips-v6"} {
+		body, err := get(ctx, httpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, splitLines(string(body))...)
+	}
+	return out, nil
+}
+
+// awsIPRanges mirrors the handful of fields we need from AWS's
+// published ip-ranges.json.
+type awsIPRanges struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+// fetchAWS returns a fetch func that pulls AWS's combined ip-ranges.json
+// and filters it down to the given service (e.g. "CLOUDFRONT").
+func fetchAWS(service string) func(context.Context, *http.Client) ([]string, error) {
+	return func(ctx context.Context, httpClient *http.Client) ([]string, error) {
+		body, err := get(ctx, httpClient, "https://ip-ranges.amazonaws.com/ip-ranges.json")
+		if err != nil {
+			return nil, err
+		}
+		var parsed awsIPRanges
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return nil, fmt.Errorf("parsing AWS ip-ranges.json: %w", err)
+		}
+		var out []string
+		for _, p := range parsed.Prefixes {
+			if p.Service == service {
+				out = append(out, p.IPPrefix)
+			}
+		}
+		for _, p := range parsed.IPv6Prefixes {
+			if p.Service == service {
+				out = append(out, p.IPv6Prefix)
+			}
+		}
+		return out, nil
+	}
+}
+
+// fetchFastly merges Fastly's published IPv4 and IPv6 CIDR lists.
+func fetchFastly(ctx context.Context, httpClient *http.Client) ([]string, error) {
+	body, err := get(ctx, httpClient, "https://api.fastly.com/public-ip-list")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Addresses     []string `json:"addresses"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing Fastly public-ip-list: %w", err)
+	}
+	return append(parsed.Addresses, parsed.IPv6Addresses...), nil
+}
+
+// fetchGoogle returns Google Cloud/user-facing published prefixes.
+func fetchGoogle(ctx context.Context, httpClient *http.Client) ([]string, error) {
+	body, err := get(ctx, httpClient, "https://www.gstatic.com/ipranges/goog.json")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+		} `json:"prefixes"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing Google goog.json: %w", err)
+	}
+	var out []string
+	for _, p := range parsed.Prefixes {
+		if p.IPv4Prefix != "" {
+			out = append(out, p.IPv4Prefix)
+		}
+		if p.IPv6Prefix != "" {
+			out = append(out, p.IPv6Prefix)
+		}
+	}
+	return out, nil
+}
+
+// fetchAzure returns Microsoft Azure's public cloud published prefixes.
+// The download URL embeds a changing GUID, so we resolve it from the
+// stable landing page first rather than hardcoding a stale link.
+func fetchAzure(ctx context.Context, httpClient *http.Client) ([]string, error) {
+	body, err := get(ctx, httpClient, "https://www.microsoft.com/en-us/download/confirmation.aspx?id=56519")
+	if err != nil {
+		return nil, err
+	}
+	var downloadURL string
+	for _, line := range strings.Split(string(body), "\"") {
+		if strings.Contains(line, "download.microsoft.com") && strings.HasSuffix(line, ".json") {
+			downloadURL = line
+			break
+		}
+	}
+	if downloadURL == "" {
+		return nil, fmt.Errorf("could not locate Azure ServiceTags download URL")
+	}
+
+	data, err := get(ctx, httpClient, downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Values []struct {
+			Properties struct {
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing Azure ServiceTags: %w", err)
+	}
+	var out []string
+	for _, v := range parsed.Values {
+		out = append(out, v.Properties.AddressPrefixes...)
+	}
+	return out, nil
+}
+
+// fetchGitHub returns GitHub's published IP ranges (hooks, pages, actions,
+// etc). /meta mixes CIDR-list fields with unrelated scalar/object fields
+// (e.g. verifiable_password_authentication is a bool, domains is an
+// object), so the response is decoded field-by-field via json.RawMessage
+// rather than as a single map[string][]string, which would fail to
+// unmarshal at all the moment it hit a non-array field.
+func fetchGitHub(ctx context.Context, httpClient *http.Client) ([]string, error) {
+	body, err := get(ctx, httpClient, "https://api.github.com/meta")
+	if err != nil {
+		return nil, err
+	}
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing GitHub meta: %w", err)
+	}
+	var out []string
+	for _, raw := range parsed {
+		var cidrs []string
+		if err := json.Unmarshal(raw, &cidrs); err != nil {
+			continue // not a CIDR list
+		}
+		out = append(out, cidrs...)
+	}
+	return out, nil
+}
+
+func splitLines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
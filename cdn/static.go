@@ -0,0 +1,58 @@
+package cdn
+
+// staticFallback is the bundled snapshot used for -offline runs and for
+// the very first lookup before Refresh has completed or a disk cache
+// exists. It carries the providers of the old hardcoded cdnProviders map
+// in package main plus google, azure, and github, with IPv6 ranges
+// added for every provider except akamai and aws_global_accelerator.
+var staticFallback = map[string][]string{
+	"cloudflare": {
+		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22",
+		"103.31.4.0/22", "141.101.64.0/18", "108.162.192.0/18",
+		"190.93.240.0/20", "188.114.96.0/20", "197.234.240.0/22",
+		"198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+		"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+		"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32",
+		"2405:b500::/32", "2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+	},
+	"cloudfront": {
+		"52.84.0.0/15", "54.230.0.0/16", "54.239.128.0/18",
+		"99.84.0.0/16", "205.251.192.0/19", "54.239.192.0/19",
+		"70.132.0.0/18", "13.32.0.0/15", "13.35.0.0/16",
+		"204.246.164.0/22", "204.246.168.0/22", "71.152.0.0/17",
+		"2600:9000::/28",
+	},
+	"aws_global_accelerator": {
+		"75.2.0.0/16", "99.77.0.0/16", "99.83.0.0/16",
+		"108.136.0.0/13", "130.176.0.0/12", "150.222.0.0/16",
+		"15.177.0.0/18", "52.93.0.0/16", "54.239.0.0/16",
+	},
+	"fastly": {
+		"23.235.32.0/20", "43.249.72.0/22", "103.244.50.0/24",
+		"103.245.222.0/23", "103.245.224.0/24", "104.156.80.0/20",
+		"140.248.64.0/18", "140.248.128.0/17", "146.75.0.0/16",
+		"151.101.0.0/16", "157.52.64.0/18", "167.82.0.0/17",
+		"167.82.128.0/20", "167.82.160.0/20", "167.82.224.0/20",
+		"172.111.64.0/18", "185.31.16.0/22", "199.27.72.0/21",
+		"199.232.0.0/16",
+		"2a04:4e40::/32", "2a04:4e42::/32",
+	},
+	"akamai": {
+		"23.0.0.0/12", "2.16.0.0/13", "23.192.0.0/11", "23.32.0.0/11",
+		"23.64.0.0/14", "23.72.0.0/13", "96.16.0.0/15", "96.6.0.0/15",
+		"104.64.0.0/10", "184.24.0.0/13", "184.50.0.0/15", "184.84.0.0/14",
+		"172.224.0.0/12", "172.240.0.0/13",
+	},
+	"google": {
+		"8.8.8.0/24", "34.64.0.0/10", "35.184.0.0/13", "35.192.0.0/14",
+		"2600:1900::/28", "2001:4860::/32",
+	},
+	"azure": {
+		"13.64.0.0/11", "20.33.0.0/16", "40.64.0.0/10", "52.96.0.0/12",
+		"2603:1000::/24",
+	},
+	"github": {
+		"140.82.112.0/20", "143.55.64.0/20", "185.199.108.0/22", "192.30.252.0/22",
+		"2a0a:a440::/29", "2606:50c0::/32",
+	},
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var csvHeader = []string{
+	"input_line", "expected_ip", "domain", "port", "status",
+	"resolved", "record_info", "strategy_used", "elapsed_ms", "timestamp",
+}
+
+// csvSink writes one row per Record, with the resolved-IP list encoded
+// as a JSON array in a single column so it survives round-tripping
+// through spreadsheet tools without losing the per-IP PTR/CDN/ASN
+// fields to a flattened, ambiguous delimiter scheme.
+type csvSink struct {
+	mu sync.Mutex
+	w  *csv.Writer
+}
+
+// NewCSVSink returns a Sink that writes CSV (with a header row) to w.
+func NewCSVSink(w io.Writer) (Sink, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("writing CSV header: %w", err)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, fmt.Errorf("writing CSV header: %w", err)
+	}
+	return &csvSink{w: cw}, nil
+}
+
+func (s *csvSink) Write(r Record) error {
+	resolved, err := json.Marshal(r.Resolved)
+	if err != nil {
+		return fmt.Errorf("encoding resolved IPs: %w", err)
+	}
+
+	row := []string{
+		r.InputLine, r.ExpectedIP, r.Domain, r.Port, r.Status,
+		string(resolved), r.RecordInfo, r.StrategyUsed,
+		strconv.FormatInt(r.ElapsedMS, 10), r.Timestamp.Format(time.RFC3339),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Write(row); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvSink) Close() error { return nil }
@@ -0,0 +1,38 @@
+package output
+
+// multiSink fans a Record out to every configured Sink - used when
+// -syslog is combined with -output/-output-file - collecting the first
+// error encountered but still writing to every sink so one going down
+// doesn't silently drop the others.
+type multiSink struct {
+	sinks []Sink
+}
+
+// Multi combines sinks into a single Sink that writes to all of them.
+// A single sink is returned unwrapped.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return &multiSink{sinks: sinks}
+}
+
+func (m *multiSink) Write(r Record) error {
+	var firstErr error
+	for _, s := range m.sinks {
+		if err := s.Write(r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m *multiSink) Close() error {
+	var firstErr error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
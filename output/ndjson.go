@@ -0,0 +1,27 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// ndjsonSink writes one JSON object per line, for piping into jq,
+// Elastic, or Splunk.
+type ndjsonSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewNDJSONSink returns a Sink that writes newline-delimited JSON to w.
+func NewNDJSONSink(w io.Writer) Sink {
+	return &ndjsonSink{enc: json.NewEncoder(w)}
+}
+
+func (s *ndjsonSink) Write(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(r)
+}
+
+func (s *ndjsonSink) Close() error { return nil }
@@ -0,0 +1,60 @@
+// Package output renders DNS lookup results to their destination -
+// stdout, a file, or syslog - in one of several structured formats.
+// It replaces the single hardcoded space-separated line the CLI used
+// to print directly, which broke on any PTR hostname containing a
+// space and had no way to carry the strategy/timing metadata that was
+// already being computed.
+package output
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ResolvedIP is one resolved address for a Record, with its reverse
+// hostname and CDN/cloud classification when known.
+type ResolvedIP struct {
+	IP  string `json:"ip"`
+	PTR string `json:"ptr,omitempty"`
+	CDN string `json:"cdn,omitempty"`
+	ASN string `json:"asn,omitempty"`
+}
+
+// Record is the structured form of a single line of input once
+// resolved, independent of how it's eventually rendered.
+type Record struct {
+	InputLine    string       `json:"input_line"`
+	ExpectedIP   string       `json:"expected_ip"`
+	Domain       string       `json:"domain"`
+	Port         string       `json:"port"`
+	Status       string       `json:"status"`
+	Resolved     []ResolvedIP `json:"resolved"`
+	RecordInfo   string       `json:"record_info,omitempty"`
+	StrategyUsed string       `json:"strategy_used,omitempty"`
+	ElapsedMS    int64        `json:"elapsed_ms"`
+	Timestamp    time.Time    `json:"timestamp"`
+}
+
+// Sink renders Records to their destination. Implementations must be
+// safe for concurrent use, since every DNS worker writes through the
+// same Sink.
+type Sink interface {
+	Write(Record) error
+	Close() error
+}
+
+// NewSink returns the Sink for the given -output kind, writing to w. An
+// empty kind is equivalent to "text".
+func NewSink(kind string, w io.Writer) (Sink, error) {
+	switch kind {
+	case "", "text":
+		return NewTextSink(w), nil
+	case "ndjson":
+		return NewNDJSONSink(w), nil
+	case "csv":
+		return NewCSVSink(w)
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want text, ndjson, or csv)", kind)
+	}
+}
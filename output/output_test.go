@@ -0,0 +1,205 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleRecord() Record {
+	return Record{
+		InputLine:    "example.com 1.2.3.4",
+		ExpectedIP:   "1.2.3.4",
+		Domain:       "example.com",
+		Port:         "443",
+		Status:       "MATCH",
+		Resolved:     []ResolvedIP{{IP: "1.2.3.4", PTR: "edge.example.net"}},
+		StrategyUsed: "system",
+		ElapsedMS:    12,
+		Timestamp:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestTextSinkWrite(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewTextSink(&buf)
+	if err := s.Write(sampleRecord()); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	got := strings.TrimSpace(buf.String())
+	want := "example.com 1.2.3.4 MATCH 1.2.3.4[edge.example.net]"
+	if got != want {
+		t.Errorf("Write() output = %q, want %q", got, want)
+	}
+}
+
+func TestTextSinkWriteWithoutResolvedOrRecordInfo(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewTextSink(&buf)
+	r := Record{InputLine: "example.com 1.2.3.4", Status: "DNS_FAILURE"}
+	if err := s.Write(r); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	got := strings.TrimSpace(buf.String())
+	want := "example.com 1.2.3.4 DNS_FAILURE"
+	if got != want {
+		t.Errorf("Write() output = %q, want %q", got, want)
+	}
+}
+
+func TestNDJSONSinkWrite(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewNDJSONSink(&buf)
+	rec := sampleRecord()
+	if err := s.Write(rec); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	var got Record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if got.Domain != rec.Domain || got.Status != rec.Status {
+		t.Errorf("decoded record = %+v, want domain/status matching %+v", got, rec)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 1 {
+		t.Errorf("expected exactly one newline-terminated JSON object, got %d", n)
+	}
+}
+
+func TestCSVSinkWrite(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := NewCSVSink(&buf)
+	if err != nil {
+		t.Fatalf("NewCSVSink() error: %v", err)
+	}
+	if err := s.Write(sampleRecord()); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV output: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2 (header + 1 record)", len(rows))
+	}
+	if !equalStrings(rows[0], csvHeader) {
+		t.Errorf("header row = %v, want %v", rows[0], csvHeader)
+	}
+	if rows[1][2] != "example.com" || rows[1][4] != "MATCH" {
+		t.Errorf("record row = %v, want domain=example.com, status=MATCH", rows[1])
+	}
+
+	var resolved []ResolvedIP
+	if err := json.Unmarshal([]byte(rows[1][5]), &resolved); err != nil {
+		t.Fatalf("resolved column is not valid JSON: %v", err)
+	}
+	if len(resolved) != 1 || resolved[0].IP != "1.2.3.4" {
+		t.Errorf("resolved column = %+v, want one entry with IP 1.2.3.4", resolved)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type failingSink struct{ err error }
+
+func (f *failingSink) Write(Record) error { return f.err }
+func (f *failingSink) Close() error       { return f.err }
+
+type recordingSink struct {
+	writes int
+	closes int
+}
+
+func (r *recordingSink) Write(Record) error { r.writes++; return nil }
+func (r *recordingSink) Close() error       { r.closes++; return nil }
+
+func TestMultiSinkFansOutAndKeepsWritingOnError(t *testing.T) {
+	failWriteErr := errors.New("write boom")
+	failCloseErr := errors.New("close boom")
+	failing := &failingSink{err: failWriteErr}
+	rec := &recordingSink{}
+
+	m := Multi(failing, rec)
+
+	if err := m.Write(sampleRecord()); !errors.Is(err, failWriteErr) {
+		t.Errorf("Write() error = %v, want %v", err, failWriteErr)
+	}
+	if rec.writes != 1 {
+		t.Errorf("rec.writes = %d, want 1 (a failing sink must not stop the others)", rec.writes)
+	}
+
+	failing.err = failCloseErr
+	if err := m.Close(); !errors.Is(err, failCloseErr) {
+		t.Errorf("Close() error = %v, want %v", err, failCloseErr)
+	}
+	if rec.closes != 1 {
+		t.Errorf("rec.closes = %d, want 1", rec.closes)
+	}
+}
+
+func TestMultiSinkUnwrapsSingleSink(t *testing.T) {
+	rec := &recordingSink{}
+	if got := Multi(rec); got != Sink(rec) {
+		t.Errorf("Multi() with one sink = %v, want the sink itself unwrapped", got)
+	}
+}
+
+func TestSyslogSinkWritesNDJSONPayload(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening for UDP: %v", err)
+	}
+	defer pc.Close()
+
+	s, err := NewSyslogSink(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("NewSyslogSink() error: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Write(sampleRecord()); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("reading UDP datagram: %v", err)
+	}
+	msg := string(buf[:n])
+
+	if !strings.Contains(msg, "san-resolver:") {
+		t.Errorf("message %q missing san-resolver tag", msg)
+	}
+	jsonStart := strings.Index(msg, "{")
+	if jsonStart < 0 {
+		t.Fatalf("message %q has no JSON payload", msg)
+	}
+	var got Record
+	if err := json.Unmarshal([]byte(msg[jsonStart:]), &got); err != nil {
+		t.Fatalf("payload is not valid JSON: %v", err)
+	}
+	if got.Domain != "example.com" {
+		t.Errorf("payload domain = %q, want %q", got.Domain, "example.com")
+	}
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// syslogSink forwards each Record as a single RFC 3164-style syslog
+// message over UDP, with the NDJSON encoding of the record as the
+// message body so a receiver can parse the full structured payload
+// without re-deriving the schema from a flattened string.
+type syslogSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink dials addr ("host:port") over UDP and returns a Sink
+// that forwards every Record there.
+func NewSyslogSink(addr string) (Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing syslog host %s: %w", addr, err)
+	}
+	return &syslogSink{conn: conn}, nil
+}
+
+func (s *syslogSink) Write(r Record) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	// <14> = facility user(1)*8 + severity info(6).
+	msg := fmt.Sprintf("<14>%s san-resolver: %s\n", time.Now().Format(time.Stamp), payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *syslogSink) Close() error { return s.conn.Close() }
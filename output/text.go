@@ -0,0 +1,48 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// textSink renders Records the way the tool always has: one
+// space-separated line per result, with "[ptr]" suffixes on resolved
+// IPs. It's the default, so existing pipelines built around this
+// format keep working unchanged.
+type textSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTextSink returns a Sink that writes the classic line-oriented
+// format to w.
+func NewTextSink(w io.Writer) Sink {
+	return &textSink{w: w}
+}
+
+func (s *textSink) Write(r Record) error {
+	parts := []string{r.InputLine, r.Status}
+	if len(r.Resolved) > 0 {
+		ips := make([]string, len(r.Resolved))
+		for i, res := range r.Resolved {
+			if res.PTR != "" {
+				ips[i] = fmt.Sprintf("%s[%s]", res.IP, res.PTR)
+			} else {
+				ips[i] = res.IP
+			}
+		}
+		parts = append(parts, strings.Join(ips, ","))
+	}
+	if r.RecordInfo != "" {
+		parts = append(parts, r.RecordInfo)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintln(s.w, strings.Join(parts, " "))
+	return err
+}
+
+func (s *textSink) Close() error { return nil }
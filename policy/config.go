@@ -0,0 +1,52 @@
+// Package policy implements per-domain DNS resolver routing loaded from
+// a YAML config file (-config resolver.yaml). It lets a user declare a
+// primary resolver set, a fallback set consulted only when a primary
+// answer looks suspicious (private IP space, or a CIDR range known to
+// serve poisoned responses), and literal per-domain overrides - useful
+// for validating a SAN list against internal and external DNS views at
+// the same time.
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the parsed form of a resolver.yaml policy file.
+type Config struct {
+	// Primary is the resolver list queried first for any domain with no
+	// matching DomainRule, as -resolvers entries (e.g. "udp://1.1.1.1:53").
+	Primary []string `yaml:"primary"`
+	// Fallback is queried when the primary answer matches FallbackIf, or
+	// when the primary resolvers all fail outright.
+	Fallback []string `yaml:"fallback"`
+	// FallbackIf is a list of CIDRs that, if any primary answer falls
+	// inside, trigger a fallback re-query (e.g. private space indicating
+	// a split-horizon or poisoned response).
+	FallbackIf []string `yaml:"fallback_if"`
+	// Domains pins specific domain patterns to their own resolver list,
+	// bypassing Primary/Fallback entirely.
+	Domains []DomainRule `yaml:"domains"`
+}
+
+// DomainRule pins a domain pattern (an exact name, or "*.suffix") to its
+// own resolver list.
+type DomainRule struct {
+	Match     string   `yaml:"match"`
+	Resolvers []string `yaml:"resolvers"`
+}
+
+// Load reads and parses a resolver.yaml policy file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sdcampbell/san-resolver/resolver"
+)
+
+// Policy is a Config compiled into ready-to-query resolver.Upstream
+// lists and CIDR matchers.
+type Policy struct {
+	primary    []resolver.Upstream
+	fallback   []resolver.Upstream
+	fallbackIf []*net.IPNet
+	domains    []compiledDomainRule
+}
+
+type compiledDomainRule struct {
+	match     string
+	resolvers []resolver.Upstream
+}
+
+// Compile parses every resolver URL and CIDR in cfg, returning a Policy
+// ready to drive resolution.
+func Compile(cfg *Config) (*Policy, error) {
+	primary, err := resolver.ParseUpstreams(strings.Join(cfg.Primary, ","))
+	if err != nil {
+		return nil, fmt.Errorf("parsing primary resolvers: %w", err)
+	}
+	fallback, err := resolver.ParseUpstreams(strings.Join(cfg.Fallback, ","))
+	if err != nil {
+		return nil, fmt.Errorf("parsing fallback resolvers: %w", err)
+	}
+
+	var fallbackIf []*net.IPNet
+	for _, cidr := range cfg.FallbackIf {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fallback_if CIDR %q: %w", cidr, err)
+		}
+		fallbackIf = append(fallbackIf, ipnet)
+	}
+
+	domains := make([]compiledDomainRule, 0, len(cfg.Domains))
+	for _, rule := range cfg.Domains {
+		resolvers, err := resolver.ParseUpstreams(strings.Join(rule.Resolvers, ","))
+		if err != nil {
+			return nil, fmt.Errorf("parsing resolvers for domain rule %q: %w", rule.Match, err)
+		}
+		domains = append(domains, compiledDomainRule{match: rule.Match, resolvers: resolvers})
+	}
+
+	if len(primary) == 0 && len(domains) == 0 {
+		return nil, fmt.Errorf("policy has no primary resolvers and no domain rules")
+	}
+
+	return &Policy{primary: primary, fallback: fallback, fallbackIf: fallbackIf, domains: domains}, nil
+}
+
+// resolversFor returns the upstream list to query for domain: a
+// matching domain rule's resolvers if any (fromRule=true), otherwise
+// Primary.
+func (p *Policy) resolversFor(domain string) (upstreams []resolver.Upstream, fromRule bool) {
+	for _, rule := range p.domains {
+		if matchDomain(rule.match, domain) {
+			return rule.resolvers, true
+		}
+	}
+	return p.primary, false
+}
+
+// needsFallback reports whether any of ips falls inside a configured
+// fallback_if CIDR, meaning the fallback resolvers should be consulted
+// instead of trusting the primary answer.
+func (p *Policy) needsFallback(ips []net.IPAddr) bool {
+	for _, ip := range ips {
+		for _, cidr := range p.fallbackIf {
+			if cidr.Contains(ip.IP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchDomain reports whether domain satisfies pattern, which is either
+// an exact match or a "*.suffix" wildcard covering suffix itself and
+// any of its subdomains.
+func matchDomain(pattern, domain string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return domain == suffix || strings.HasSuffix(domain, "."+suffix)
+	}
+	return pattern == domain
+}
+
+// Resolve looks up domain under p's routing rules: it queries the
+// domain's matching resolver list (or Primary, absent a rule), and - if
+// that answer lands inside a fallback_if CIDR, or fails outright - also
+// queries Fallback and prefers its answer. Domain-rule matches skip
+// Fallback entirely, since they're assumed authoritative for their own
+// namespace (e.g. an internal resolver for *.corp.example).
+func Resolve(ctx context.Context, p *Policy, domain string, timeout time.Duration, cache *resolver.Cache[[]net.IPAddr], fallbackTTL time.Duration, version resolver.IPVersion) ([]net.IPAddr, error) {
+	upstreams, fromRule := p.resolversFor(domain)
+	ips, err := resolver.RaceIPAddr(ctx, upstreams, domain, timeout, cache, fallbackTTL, version)
+
+	if fromRule || len(p.fallback) == 0 {
+		return ips, err
+	}
+	if err == nil && !p.needsFallback(ips) {
+		return ips, nil
+	}
+
+	fbIPs, fbErr := resolver.RaceIPAddr(ctx, p.fallback, domain, timeout, cache, fallbackTTL, version)
+	if fbErr == nil {
+		return fbIPs, nil
+	}
+	if err == nil {
+		// Primary succeeded (even if it looked suspicious) and fallback
+		// is unreachable; a suspect answer beats none at all.
+		return ips, nil
+	}
+	return nil, fbErr
+}
@@ -0,0 +1,26 @@
+package policy
+
+import "testing"
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		pattern string
+		domain  string
+		want    bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "example.com", true},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "notexample.com", false},
+		{"*.example.com", "example.com.evil.com", false},
+		{"internal.corp", "internal.corp", true},
+		{"internal.corp", "other.corp", false},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.pattern, tt.domain); got != tt.want {
+			t.Errorf("matchDomain(%q, %q) = %v, want %v", tt.pattern, tt.domain, got, tt.want)
+		}
+	}
+}
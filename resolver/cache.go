@@ -0,0 +1,165 @@
+package resolver
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheKey identifies a single cached lookup: the domain, the query
+// type (e.g. "A", "PTR"), and which resolver answered it, so results
+// from different upstreams are never conflated.
+type CacheKey struct {
+	Domain   string
+	QType    string
+	Resolver string
+}
+
+func (k CacheKey) String() string {
+	return k.Resolver + "|" + k.QType + "|" + k.Domain
+}
+
+type cacheEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// Cache deduplicates concurrent lookups for the same CacheKey via
+// singleflight, and memoizes successful results in an LRU-bounded map
+// keyed on (domain, qtype, resolver) until their TTL expires. Large SAN
+// input files routinely repeat the same CDN hostnames across thousands
+// of lines; without this, every worker re-resolves and re-PTRs them
+// independently.
+type Cache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> list element wrapping *cacheEntry[V]
+	order   *list.List               // front = most recently used
+	maxSize int
+	group   singleflight.Group
+
+	hits   int64
+	misses int64
+}
+
+// NewCache returns a Cache holding at most maxSize entries, evicting the
+// least recently used entry once full. maxSize <= 0 means unbounded.
+func NewCache[V any](maxSize int) *Cache[V] {
+	return &Cache[V]{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+	}
+}
+
+func (c *Cache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *Cache[V]) set(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry[V])
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&cacheEntry[V]{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	c.entries[key] = elem
+
+	for c.maxSize > 0 && c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[V]).key)
+	}
+}
+
+// Lookup returns the cached value for key if present and unexpired;
+// otherwise it calls fn to resolve it, deduplicating concurrent callers
+// for the same key via singleflight. fn reports how long its result may
+// be cached (e.g. the authoritative answer's TTL); fallbackTTL is used
+// whenever fn reports zero, which is the common case for stdlib
+// resolvers that don't expose a TTL at all.
+//
+// Because singleflight invokes fn at most once per key and shares its
+// result with every concurrent caller deduped onto that key, fn must not
+// be tied to any single one of those callers' cancelable contexts - one
+// caller's context being cancelled (e.g. a sibling strategy won its own
+// race) would otherwise fail every other caller waiting on the same key,
+// including ones with a perfectly healthy context of their own. fn is
+// instead given a context stripped of ctx's cancellation and deadline
+// (ctx's values, e.g. for tracing, are preserved); callers that want the
+// fetch itself time-bounded must do so independently, as every Resolver
+// and Upstream implementation in this package already does via its own
+// timeout parameter.
+func (c *Cache[V]) Lookup(ctx context.Context, key CacheKey, fallbackTTL time.Duration, fn func(context.Context) (V, time.Duration, error)) (V, error) {
+	k := key.String()
+
+	if v, ok := c.get(k); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return v, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	fetchCtx := context.WithoutCancel(ctx)
+	v, err, _ := c.group.Do(k, func() (interface{}, error) {
+		v, ttl, err := fn(fetchCtx)
+		if err == nil {
+			if ttl <= 0 {
+				ttl = fallbackTTL
+			}
+			c.set(k, v, ttl)
+		}
+		return v, err
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// Stats returns cumulative hit/miss counts, for -v output.
+func (c *Cache[V]) Stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// NewRecordCache returns a Cache suitable for NewCached, sized to hold
+// maxSize typed-record answers.
+func NewRecordCache(maxSize int) *Cache[*dns.Msg] {
+	return NewCache[*dns.Msg](maxSize)
+}
+
+// NewIPCache returns a Cache suitable for RaceIPAddr, sized to hold
+// maxSize A/AAAA answers.
+func NewIPCache(maxSize int) *Cache[[]net.IPAddr] {
+	return NewCache[[]net.IPAddr](maxSize)
+}
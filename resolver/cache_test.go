@@ -0,0 +1,100 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheTTLExpiry(t *testing.T) {
+	c := NewCache[int](0)
+	calls := 0
+	fetch := func(ctx context.Context) (int, time.Duration, error) {
+		calls++
+		return calls, 10 * time.Millisecond, nil
+	}
+
+	v, err := c.Lookup(context.Background(), CacheKey{Domain: "a"}, time.Minute, fetch)
+	if err != nil || v != 1 {
+		t.Fatalf("got v=%d err=%v, want 1, nil", v, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	v, err = c.Lookup(context.Background(), CacheKey{Domain: "a"}, time.Minute, fetch)
+	if err != nil || v != 2 {
+		t.Fatalf("expected expired entry to be refetched: got v=%d err=%v", v, err)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache[int](2)
+	fetch := func(n int) func(context.Context) (int, time.Duration, error) {
+		return func(ctx context.Context) (int, time.Duration, error) {
+			return n, time.Minute, nil
+		}
+	}
+
+	mustLookup := func(domain string, n int) {
+		t.Helper()
+		v, err := c.Lookup(context.Background(), CacheKey{Domain: domain}, time.Minute, fetch(n))
+		if err != nil || v != n {
+			t.Fatalf("Lookup(%q) = %d, %v; want %d, nil", domain, v, err, n)
+		}
+	}
+
+	mustLookup("a", 1)
+	mustLookup("b", 2)
+	mustLookup("a", 1) // touch "a" so "b" becomes the least recently used
+	mustLookup("c", 3) // over maxSize=2, evicts "b"
+
+	hitsBefore, _ := c.Stats()
+	mustLookup("b", 4) // "b" was evicted, so this must miss and refetch
+	hitsAfter, _ := c.Stats()
+	if hitsAfter != hitsBefore {
+		t.Fatalf("expected a cache miss for evicted key %q, got a hit", "b")
+	}
+}
+
+// TestCacheLookupSurvivesSiblingCallerCancellation guards against a
+// singleflight-shared fetch being killed by the cancellation of whichever
+// caller happened to be its leader - e.g. a sibling strategy winning that
+// particular caller's own race - even though another caller sharing the
+// same key has a perfectly healthy context of its own.
+func TestCacheLookupSurvivesSiblingCallerCancellation(t *testing.T) {
+	c := NewCache[string](0)
+	key := CacheKey{Domain: "example.com"}
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	fetch := func(fetchCtx context.Context) (string, time.Duration, error) {
+		close(started)
+		<-proceed
+		return "1.2.3.4", 0, fetchCtx.Err()
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := c.Lookup(leaderCtx, key, time.Minute, fetch)
+		leaderDone <- err
+	}()
+
+	<-started // the leader's fetch is now in flight inside singleflight
+
+	followerDone := make(chan error, 1)
+	go func() {
+		_, err := c.Lookup(context.Background(), key, time.Minute, fetch)
+		followerDone <- err
+	}()
+
+	cancelLeader() // simulate a sibling strategy winning the leader's race
+	close(proceed)
+
+	if err := <-leaderDone; err != nil {
+		t.Fatalf("leader Lookup returned error: %v", err)
+	}
+	if err := <-followerDone; err != nil {
+		t.Fatalf("follower Lookup returned error despite its own healthy context: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Package resolver provides typed DNS record lookups backed by
+// github.com/miekg/dns. It replaces the net.Resolver-based IP lookups
+// that used to live directly in package main with a Resolver interface
+// that can also answer CNAME, MX, NS, TXT, and CAA queries, none of
+// which Go's standard library exposes directly.
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RecordType identifies a DNS record type the CLI knows how to request
+// and print.
+type RecordType string
+
+const (
+	TypeA     RecordType = "A"
+	TypeAAAA  RecordType = "AAAA"
+	TypeCNAME RecordType = "CNAME"
+	TypeMX    RecordType = "MX"
+	TypeNS    RecordType = "NS"
+	TypeTXT   RecordType = "TXT"
+	TypeCAA   RecordType = "CAA"
+)
+
+// AllRecordTypes is every record type ParseRecordTypes accepts, and what
+// it returns for an empty string.
+var AllRecordTypes = []RecordType{TypeA, TypeAAAA, TypeCNAME, TypeMX, TypeNS, TypeTXT, TypeCAA}
+
+// ParseRecordTypes parses a comma-separated -records flag value such as
+// "A,AAAA,MX" into a slice of RecordType. An empty string returns
+// AllRecordTypes.
+func ParseRecordTypes(s string) ([]RecordType, error) {
+	if strings.TrimSpace(s) == "" {
+		return AllRecordTypes, nil
+	}
+
+	var out []RecordType
+	for _, part := range strings.Split(s, ",") {
+		rt := RecordType(strings.ToUpper(strings.TrimSpace(part)))
+		switch rt {
+		case TypeA, TypeAAAA, TypeCNAME, TypeMX, TypeNS, TypeTXT, TypeCAA:
+			out = append(out, rt)
+		default:
+			return nil, fmt.Errorf("unknown record type %q", part)
+		}
+	}
+	return out, nil
+}
+
+// Resolver looks up individual DNS record types against a single
+// upstream nameserver. Implementations must be safe for concurrent use.
+type Resolver interface {
+	LookupA(ctx context.Context, domain string) ([]string, error)
+	LookupAAAA(ctx context.Context, domain string) ([]string, error)
+	LookupCNAME(ctx context.Context, domain string) (string, error)
+	LookupMX(ctx context.Context, domain string) ([]string, error)
+	LookupNS(ctx context.Context, domain string) ([]string, error)
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+	LookupCAA(ctx context.Context, domain string) ([]string, error)
+}
+
+// client is the default Resolver implementation. It queries a single
+// nameserver address (host:port) over UDP using miekg/dns.
+type client struct {
+	server      string
+	timeout     time.Duration
+	cache       *Cache[*dns.Msg]
+	fallbackTTL time.Duration
+}
+
+// New returns a Resolver that queries server (e.g. "8.8.8.8:53") over UDP.
+func New(server string, timeout time.Duration) Resolver {
+	return &client{server: server, timeout: timeout}
+}
+
+// NewCached is like New, but deduplicates concurrent queries for the
+// same (domain, qtype) and memoizes answers in cache for the
+// authoritative TTL, falling back to fallbackTTL if an answer carries
+// none (e.g. an empty NODATA response).
+func NewCached(server string, timeout time.Duration, cache *Cache[*dns.Msg], fallbackTTL time.Duration) Resolver {
+	return &client{server: server, timeout: timeout, cache: cache, fallbackTTL: fallbackTTL}
+}
+
+func (c *client) query(ctx context.Context, domain string, qtype uint16) (*dns.Msg, error) {
+	fetch := func(ctx context.Context) (*dns.Msg, time.Duration, error) {
+		m := new(dns.Msg)
+		m.SetQuestion(dns.Fqdn(domain), qtype)
+		m.RecursionDesired = true
+
+		dc := &dns.Client{Timeout: c.timeout, Net: "udp"}
+		resp, _, err := dc.ExchangeContext(ctx, m, c.server)
+		if err != nil {
+			return nil, 0, fmt.Errorf("querying %s for %s %s: %w", c.server, domain, dns.TypeToString[qtype], err)
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			return nil, 0, fmt.Errorf("%s answered %s %s with rcode %s", c.server, domain, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+		}
+		return resp, answerTTL(resp), nil
+	}
+
+	if c.cache == nil {
+		resp, _, err := fetch(ctx)
+		return resp, err
+	}
+	key := CacheKey{Domain: domain, QType: dns.TypeToString[qtype], Resolver: c.server}
+	return c.cache.Lookup(ctx, key, c.fallbackTTL, fetch)
+}
+
+// answerTTL returns the lowest TTL among an answer's resource records,
+// or 0 if it has none (callers fall back to a configured default).
+func answerTTL(resp *dns.Msg) time.Duration {
+	var min uint32
+	found := false
+	for _, rr := range resp.Answer {
+		ttl := rr.Header().Ttl
+		if !found || ttl < min {
+			min = ttl
+			found = true
+		}
+	}
+	if !found {
+		return 0
+	}
+	return time.Duration(min) * time.Second
+}
+
+func (c *client) LookupA(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeA)
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, rr := range resp.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A.String())
+		}
+	}
+	return ips, nil
+}
+
+func (c *client) LookupAAAA(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeAAAA)
+	if err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, rr := range resp.Answer {
+		if aaaa, ok := rr.(*dns.AAAA); ok {
+			ips = append(ips, aaaa.AAAA.String())
+		}
+	}
+	return ips, nil
+}
+
+func (c *client) LookupCNAME(ctx context.Context, domain string) (string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeCNAME)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range resp.Answer {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			return strings.TrimSuffix(cname.Target, "."), nil
+		}
+	}
+	return "", nil
+}
+
+func (c *client) LookupMX(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	var mxs []string
+	for _, rr := range resp.Answer {
+		if mx, ok := rr.(*dns.MX); ok {
+			mxs = append(mxs, fmt.Sprintf("%d %s", mx.Preference, strings.TrimSuffix(mx.Mx, ".")))
+		}
+	}
+	return mxs, nil
+}
+
+func (c *client) LookupNS(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	var nss []string
+	for _, rr := range resp.Answer {
+		if ns, ok := rr.(*dns.NS); ok {
+			nss = append(nss, strings.TrimSuffix(ns.Ns, "."))
+		}
+	}
+	return nss, nil
+}
+
+func (c *client) LookupTXT(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txts []string
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			txts = append(txts, strings.Join(txt.Txt, ""))
+		}
+	}
+	return txts, nil
+}
+
+func (c *client) LookupCAA(ctx context.Context, domain string) ([]string, error) {
+	resp, err := c.query(ctx, domain, dns.TypeCAA)
+	if err != nil {
+		return nil, err
+	}
+	var caas []string
+	for _, rr := range resp.Answer {
+		if caa, ok := rr.(*dns.CAA); ok {
+			caas = append(caas, fmt.Sprintf("%d %s %q", caa.Flag, caa.Tag, caa.Value))
+		}
+	}
+	return caas, nil
+}
+
+// LookupAll queries every record type in types against r and returns a
+// map from type to its formatted answers. A failed lookup is recorded
+// as a single "ERROR: ..." entry rather than dropped, so callers can
+// still print it alongside the types that succeeded.
+func LookupAll(ctx context.Context, r Resolver, domain string, types []RecordType) map[RecordType][]string {
+	out := make(map[RecordType][]string, len(types))
+	for _, t := range types {
+		var (
+			vals []string
+			err  error
+		)
+		switch t {
+		case TypeA:
+			vals, err = r.LookupA(ctx, domain)
+		case TypeAAAA:
+			vals, err = r.LookupAAAA(ctx, domain)
+		case TypeCNAME:
+			var cname string
+			cname, err = r.LookupCNAME(ctx, domain)
+			if cname != "" {
+				vals = []string{cname}
+			}
+		case TypeMX:
+			vals, err = r.LookupMX(ctx, domain)
+		case TypeNS:
+			vals, err = r.LookupNS(ctx, domain)
+		case TypeTXT:
+			vals, err = r.LookupTXT(ctx, domain)
+		case TypeCAA:
+			vals, err = r.LookupCAA(ctx, domain)
+		}
+		if err != nil {
+			out[t] = []string{fmt.Sprintf("ERROR: %v", err)}
+			continue
+		}
+		out[t] = vals
+	}
+	return out
+}
+
+// FormatRecords renders the output of LookupAll as "TYPE=val1,val2 ..."
+// pairs in the order given by types, for inline inclusion in the
+// existing line-oriented CLI output.
+func FormatRecords(records map[RecordType][]string, types []RecordType) string {
+	var parts []string
+	for _, t := range types {
+		vals := records[t]
+		if len(vals) == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", t, strings.Join(vals, ",")))
+	}
+	return strings.Join(parts, " ")
+}
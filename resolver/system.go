@@ -0,0 +1,25 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NewSystem returns a Resolver backed by the first nameserver in the
+// host's resolv.conf, queried directly over UDP via miekg/dns - the
+// same transport every other Resolver in this package uses, rather
+// than shelling out to net.Resolver/cgo for this one strategy.
+func NewSystem(timeout time.Duration, cache *Cache[*dns.Msg], fallbackTTL time.Duration) (Resolver, error) {
+	cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("reading system resolver config: %w", err)
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("no nameservers found in system resolver config")
+	}
+	server := net.JoinHostPort(cfg.Servers[0], cfg.Port)
+	return NewCached(server, timeout, cache, fallbackTTL), nil
+}
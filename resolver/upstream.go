@@ -0,0 +1,317 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Protocol identifies the transport an Upstream is reached over.
+type Protocol string
+
+const (
+	ProtoUDP Protocol = "udp"
+	ProtoTCP Protocol = "tcp"
+	ProtoDoT Protocol = "dot"
+	ProtoDoH Protocol = "doh"
+)
+
+// Upstream is a single configured nameserver, reachable over plain UDP
+// or TCP, DNS-over-TLS, or DNS-over-HTTPS. It is the unit the -resolvers
+// flag and the -protocols filter operate on.
+type Upstream struct {
+	Protocol Protocol
+	// Address is host:port for udp/tcp/dot, or the full query URL for doh.
+	Address string
+}
+
+// ParseUpstream parses a single -resolvers entry, e.g.
+// "udp://1.1.1.1:53", "tcp://9.9.9.9:53", "tls://1.1.1.1:853", or
+// "https://cloudflare-dns.com/dns-query".
+func ParseUpstream(raw string) (Upstream, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Upstream{}, fmt.Errorf("invalid resolver URL %q (want udp://, tcp://, tls://, or https://)", raw)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return Upstream{Protocol: ProtoUDP, Address: u.Host}, nil
+	case "tcp":
+		return Upstream{Protocol: ProtoTCP, Address: u.Host}, nil
+	case "tls":
+		return Upstream{Protocol: ProtoDoT, Address: u.Host}, nil
+	case "https":
+		return Upstream{Protocol: ProtoDoH, Address: raw}, nil
+	default:
+		return Upstream{}, fmt.Errorf("unsupported resolver scheme %q", u.Scheme)
+	}
+}
+
+// ParseUpstreams parses a comma-separated -resolvers flag value.
+func ParseUpstreams(raw string) ([]Upstream, error) {
+	var out []Upstream
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		u, err := ParseUpstream(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// ParseProtocols parses a comma-separated -protocols flag value such as
+// "udp,dot,doh" into a set usable for filtering a default upstream list.
+func ParseProtocols(raw string) (map[Protocol]bool, error) {
+	out := make(map[Protocol]bool)
+	for _, part := range strings.Split(raw, ",") {
+		p := Protocol(strings.ToLower(strings.TrimSpace(part)))
+		switch p {
+		case ProtoUDP, ProtoTCP, ProtoDoT, ProtoDoH:
+			out[p] = true
+		default:
+			return nil, fmt.Errorf("unknown protocol %q", part)
+		}
+	}
+	return out, nil
+}
+
+// LookupIPAddr resolves domain against this upstream, over whichever
+// transport it's configured for, racing the record types version calls
+// for (A, AAAA, or both) concurrently and returning as soon as one
+// succeeds - Happy-Eyeballs style, so a dual-stack query doesn't wait on
+// a slow or filtered AAAA response when A already answered (or vice
+// versa). The returned TTL is the one carried by the winning answer.
+func (u Upstream) LookupIPAddr(ctx context.Context, domain string, timeout time.Duration, version IPVersion) ([]net.IPAddr, time.Duration, error) {
+	if u.Protocol == ProtoDoH {
+		return u.lookupDoH(ctx, domain, timeout, version)
+	}
+
+	netName := map[Protocol]string{ProtoUDP: "udp", ProtoTCP: "tcp", ProtoDoT: "tcp-tls"}[u.Protocol]
+	if netName == "" {
+		return nil, 0, fmt.Errorf("unsupported protocol %q", u.Protocol)
+	}
+	dc := &dns.Client{Net: netName, Timeout: timeout}
+
+	qtypes := version.QTypes()
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		ips []net.IPAddr
+		ttl time.Duration
+		err error
+	}
+	results := make(chan answer, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(domain), qtype)
+			m.RecursionDesired = true
+
+			resp, _, err := dc.ExchangeContext(raceCtx, m, u.Address)
+			if err != nil {
+				results <- answer{err: err}
+				return
+			}
+			var (
+				ips   []net.IPAddr
+				ttl   time.Duration
+				found bool
+			)
+			for _, rr := range resp.Answer {
+				switch rec := rr.(type) {
+				case *dns.A:
+					ips = append(ips, net.IPAddr{IP: rec.A})
+				case *dns.AAAA:
+					ips = append(ips, net.IPAddr{IP: rec.AAAA})
+				}
+				if recTTL := time.Duration(rr.Header().Ttl) * time.Second; !found || recTTL < ttl {
+					ttl, found = recTTL, true
+				}
+			}
+			results <- answer{ips: ips, ttl: ttl}
+		}(qtype)
+	}
+
+	var lastErr error
+	for i := 0; i < len(qtypes); i++ {
+		a := <-results
+		if a.err == nil && len(a.ips) > 0 {
+			return a.ips, a.ttl, nil
+		}
+		if a.err != nil {
+			lastErr = a.err
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no records for %s", domain)
+	}
+	return nil, 0, fmt.Errorf("%s (%s): %w", u.Address, u.Protocol, lastErr)
+}
+
+// lookupDoH performs an RFC 8484 DNS-over-HTTPS query using the wire
+// ("application/dns-message") format against u.Address, which is the
+// full query URL (e.g. "https://cloudflare-dns.com/dns-query"). Like
+// LookupIPAddr, it races version's record types concurrently and
+// returns as soon as one succeeds.
+func (u Upstream) lookupDoH(ctx context.Context, domain string, timeout time.Duration, version IPVersion) ([]net.IPAddr, time.Duration, error) {
+	httpClient := &http.Client{Timeout: timeout}
+
+	qtypes := version.QTypes()
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		ips []net.IPAddr
+		ttl time.Duration
+		err error
+	}
+	results := make(chan answer, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(domain), qtype)
+			m.RecursionDesired = true
+			m.Id = 0 // required to be zero for DoH GET/POST caching, per RFC 8484
+
+			packed, err := m.Pack()
+			if err != nil {
+				results <- answer{err: fmt.Errorf("packing DoH query: %w", err)}
+				return
+			}
+
+			req, err := http.NewRequestWithContext(raceCtx, http.MethodPost, u.Address, bytes.NewReader(packed))
+			if err != nil {
+				results <- answer{err: fmt.Errorf("building DoH request: %w", err)}
+				return
+			}
+			req.Header.Set("Content-Type", "application/dns-message")
+			req.Header.Set("Accept", "application/dns-message")
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				results <- answer{err: err}
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				results <- answer{err: err}
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				results <- answer{err: fmt.Errorf("DoH query returned HTTP %d", resp.StatusCode)}
+				return
+			}
+
+			msg := new(dns.Msg)
+			if err := msg.Unpack(body); err != nil {
+				results <- answer{err: fmt.Errorf("unpacking DoH response: %w", err)}
+				return
+			}
+			var (
+				ips   []net.IPAddr
+				ttl   time.Duration
+				found bool
+			)
+			for _, rr := range msg.Answer {
+				switch rec := rr.(type) {
+				case *dns.A:
+					ips = append(ips, net.IPAddr{IP: rec.A})
+				case *dns.AAAA:
+					ips = append(ips, net.IPAddr{IP: rec.AAAA})
+				}
+				if recTTL := time.Duration(rr.Header().Ttl) * time.Second; !found || recTTL < ttl {
+					ttl, found = recTTL, true
+				}
+			}
+			results <- answer{ips: ips, ttl: ttl}
+		}(qtype)
+	}
+
+	var lastErr error
+	for i := 0; i < len(qtypes); i++ {
+		a := <-results
+		if a.err == nil && len(a.ips) > 0 {
+			return a.ips, a.ttl, nil
+		}
+		if a.err != nil {
+			lastErr = a.err
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no records for %s", domain)
+	}
+	return nil, 0, fmt.Errorf("%s: %w", u.Address, lastErr)
+}
+
+// RaceIPAddr queries every upstream in parallel and returns the first
+// successful answer. Returns an error only if all upstreams fail. When
+// cache is nil, the losing lookups are cancelled once one succeeds. When
+// cache is non-nil, each upstream's result is deduplicated and memoized
+// independently (keyed by its own address and the requested version, so
+// a -ip-version=4 lookup never serves a cached -ip-version=6 answer or
+// vice versa), and the losing lookups are not cancelled: they run until
+// they answer or hit timeout.
+func RaceIPAddr(ctx context.Context, upstreams []Upstream, domain string, timeout time.Duration, cache *Cache[[]net.IPAddr], fallbackTTL time.Duration, version IPVersion) ([]net.IPAddr, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no upstreams configured")
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		ips []net.IPAddr
+		err error
+	}
+	results := make(chan result, len(upstreams))
+
+	for _, u := range upstreams {
+		go func(u Upstream) {
+			var (
+				ips []net.IPAddr
+				err error
+			)
+			if cache != nil {
+				// Routed through the cache, so this fetch may be shared
+				// with unrelated callers via singleflight - pass ctx, not
+				// raceCtx, so Cache.Lookup detaches it from this race's
+				// own cancellation rather than any winning sibling
+				// upstream cutting off another caller's in-flight fetch.
+				fetch := func(fetchCtx context.Context) ([]net.IPAddr, time.Duration, error) {
+					return u.LookupIPAddr(fetchCtx, domain, timeout, version)
+				}
+				key := CacheKey{Domain: domain, QType: "IP-" + string(version), Resolver: string(u.Protocol) + "://" + u.Address}
+				ips, err = cache.Lookup(ctx, key, fallbackTTL, fetch)
+			} else {
+				ips, _, err = u.LookupIPAddr(raceCtx, domain, timeout, version)
+			}
+			results <- result{ips: ips, err: err}
+		}(u)
+	}
+
+	var lastErr error
+	for i := 0; i < len(upstreams); i++ {
+		r := <-results
+		if r.err == nil && len(r.ips) > 0 {
+			return r.ips, nil
+		}
+		lastErr = r.err
+	}
+	return nil, fmt.Errorf("all %d upstream(s) failed, last error: %w", len(upstreams), lastErr)
+}
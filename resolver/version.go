@@ -0,0 +1,116 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// IPVersion selects which address family a lookup should return, driven
+// by the CLI's -ip-version flag.
+type IPVersion string
+
+const (
+	IPv4   IPVersion = "4"
+	IPv6   IPVersion = "6"
+	IPBoth IPVersion = "both"
+)
+
+// ParseIPVersion parses a -ip-version flag value. An empty string means
+// IPBoth, the default.
+func ParseIPVersion(s string) (IPVersion, error) {
+	switch IPVersion(s) {
+	case "":
+		return IPBoth, nil
+	case IPv4, IPv6, IPBoth:
+		return IPVersion(s), nil
+	default:
+		return "", fmt.Errorf("unknown ip version %q (want 4, 6, or both)", s)
+	}
+}
+
+// QTypes returns the miekg/dns record types a Happy-Eyeballs-style
+// lookup should fire concurrently for this version.
+func (v IPVersion) QTypes() []uint16 {
+	switch v {
+	case IPv4:
+		return []uint16{dns.TypeA}
+	case IPv6:
+		return []uint16{dns.TypeAAAA}
+	default:
+		return []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+}
+
+// Accepts reports whether ip belongs to this version's address family.
+func (v IPVersion) Accepts(ip net.IP) bool {
+	switch v {
+	case IPv4:
+		return ip.To4() != nil
+	case IPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// LookupIPAddr resolves domain's addresses via r, racing the A and AAAA
+// queries version calls for concurrently and returning as soon as one
+// succeeds - Happy-Eyeballs style (RFC 8305), applied to the lookup
+// itself rather than the TCP connect. The single-server
+// "system"/-force-google/-force-cloudflare resolvers use it; the upstream
+// race does not go through a Resolver but applies the same A/AAAA race
+// in Upstream.LookupIPAddr.
+func LookupIPAddr(ctx context.Context, r Resolver, domain string, version IPVersion) ([]net.IPAddr, error) {
+	qtypes := version.QTypes()
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		ips []net.IPAddr
+		err error
+	}
+	results := make(chan answer, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			var (
+				addrs []string
+				err   error
+			)
+			switch qtype {
+			case dns.TypeA:
+				addrs, err = r.LookupA(raceCtx, domain)
+			case dns.TypeAAAA:
+				addrs, err = r.LookupAAAA(raceCtx, domain)
+			}
+			if err != nil {
+				results <- answer{err: err}
+				return
+			}
+			ips := make([]net.IPAddr, 0, len(addrs))
+			for _, a := range addrs {
+				if ip := net.ParseIP(a); ip != nil {
+					ips = append(ips, net.IPAddr{IP: ip})
+				}
+			}
+			results <- answer{ips: ips}
+		}(qtype)
+	}
+
+	var lastErr error
+	for i := 0; i < len(qtypes); i++ {
+		a := <-results
+		if a.err == nil && len(a.ips) > 0 {
+			return a.ips, nil
+		}
+		if a.err != nil {
+			lastErr = a.err
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no records for %s", domain)
+	}
+	return nil, lastErr
+}
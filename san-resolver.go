@@ -5,12 +5,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"net"
+	"net/netip"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/sdcampbell/san-resolver/cdn"
+	"github.com/sdcampbell/san-resolver/output"
+	"github.com/sdcampbell/san-resolver/policy"
+	"github.com/sdcampbell/san-resolver/resolver"
 )
 
 const (
@@ -20,80 +28,263 @@ const (
 	InputBufferSize = 1000
 	// Timeout for DNS lookups
 	DNSTimeout = 5 * time.Second
+	// Upstream nameserver used for the typed record lookups (-records)
+	RecordServer = "1.1.1.1:53"
+	// Nameservers used by -force-google and -force-cloudflare
+	GoogleServer     = "8.8.8.8:53"
+	CloudflareServer = "1.1.1.1:53"
+	// Maximum entries kept in each LRU lookup cache
+	CacheMaxEntries = 10000
+	// Fallback TTL used when a resolver doesn't expose one (e.g. the
+	// stdlib net.Resolver reverse lookups, or a NODATA answer)
+	DefaultCacheTTL = 5 * time.Minute
 )
 
-// Known CDN IP ranges and ASNs for detection
-var cdnProviders = map[string][]string{
-	"cloudflare": {
-		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22",
-		"103.31.4.0/22", "141.101.64.0/18", "108.162.192.0/18",
-		"190.93.240.0/20", "188.114.96.0/20", "197.234.240.0/22",
-		"198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
-		"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
-	},
-	"cloudfront": {
-		"52.84.0.0/15", "54.230.0.0/16", "54.239.128.0/18",
-		"99.84.0.0/16", "205.251.192.0/19", "54.239.192.0/19",
-		"70.132.0.0/18", "13.32.0.0/15", "13.35.0.0/16",
-		"204.246.164.0/22", "204.246.168.0/22", "71.152.0.0/17",
-	},
-	"aws_global_accelerator": {
-		"75.2.0.0/16", "99.77.0.0/16", "99.83.0.0/16",
-		"108.136.0.0/13", "130.176.0.0/12", "150.222.0.0/16",
-		"15.177.0.0/18", "52.93.0.0/16", "54.239.0.0/16",
-	},
-	"fastly": {
-		"23.235.32.0/20", "43.249.72.0/22", "103.244.50.0/24",
-		"103.245.222.0/23", "103.245.224.0/24", "104.156.80.0/20",
-		"140.248.64.0/18", "140.248.128.0/17", "146.75.0.0/16",
-		"151.101.0.0/16", "157.52.64.0/18", "167.82.0.0/17",
-		"167.82.128.0/20", "167.82.160.0/20", "167.82.224.0/20",
-		"172.111.64.0/18", "185.31.16.0/22", "199.27.72.0/21",
-		"199.232.0.0/16",
-	},
-	"akamai": {
-		"23.0.0.0/12", "2.16.0.0/13", "23.192.0.0/11", "23.32.0.0/11",
-		"23.64.0.0/14", "23.72.0.0/13", "96.16.0.0/15", "96.6.0.0/15",
-		"104.64.0.0/10", "184.24.0.0/13", "184.50.0.0/15", "184.84.0.0/14",
-		"172.224.0.0/12", "172.240.0.0/13",
-	},
-}
-
 type DNSRequest struct {
 	line       string
-	expectedIP string
+	expectedIP netip.Addr
+	port       string
 	domain     string
 }
 
+// DNSResult is the outcome of resolving one DNSRequest, in the
+// structured form output.Record is built from.
 type DNSResult struct {
-	line        string
-	shouldPrint bool
-	status      string
-	resolvedIPs []string // Now stores formatted "IP[hostname]" or "IP" strings
+	line         string
+	shouldPrint  bool
+	status       string
+	expectedIP   string
+	port         string
+	domain       string
+	resolved     []output.ResolvedIP
+	recordInfo   string // "TYPE=val,val ..." from -records, empty if -records wasn't set
+	strategyUsed string
+	elapsedMS    int64
+	timestamp    time.Time
+}
+
+// toRecord converts a DNSResult into the structured record a Sink
+// renders.
+func (r DNSResult) toRecord() output.Record {
+	return output.Record{
+		InputLine:    r.line,
+		ExpectedIP:   r.expectedIP,
+		Domain:       r.domain,
+		Port:         r.port,
+		Status:       r.status,
+		Resolved:     r.resolved,
+		RecordInfo:   r.recordInfo,
+		StrategyUsed: r.strategyUsed,
+		ElapsedMS:    r.elapsedMS,
+		Timestamp:    r.timestamp,
+	}
+}
+
+// dnsConfig bundles the flags and shared resolver state every worker
+// needs, so growing the feature set doesn't keep growing a positional
+// parameter list.
+type dnsConfig struct {
+	dnsTimeout  time.Duration
+	forceGoogle bool
+	forceCF     bool
+	noSystemDNS bool
+	verbose     bool
+
+	recordResolver resolver.Resolver
+	recordTypes    []resolver.RecordType
+
+	// systemResolver, googleResolver, and cfResolver each query a single
+	// nameserver over miekg/dns (nil if unavailable): the host's own
+	// resolv.conf, 8.8.8.8, and 1.1.1.1 respectively.
+	systemResolver resolver.Resolver
+	googleResolver resolver.Resolver
+	cfResolver     resolver.Resolver
+
+	upstreams []resolver.Upstream
+	ipCache   *resolver.Cache[[]net.IPAddr]
+
+	reverseCache *resolver.Cache[string]
+	cacheTTL     time.Duration
+
+	cdnRegistry *cdn.Registry
+	cdnASN      cdn.ASNLookup
+
+	policy *policy.Policy
+
+	sink output.Sink
+
+	ipVersion resolver.IPVersion
 }
 
 func main() {
 	// Command line flags for DNS configuration
 	var (
-		workers     = flag.Int("workers", NumWorkers, "Number of concurrent DNS workers")
-		bufferSize  = flag.Int("buffer", InputBufferSize, "Input buffer size")
-		dnsTimeout  = flag.Duration("timeout", DNSTimeout, "DNS lookup timeout")
-		forceGoogle = flag.Bool("force-google", false, "Force Google DNS (8.8.8.8) only")
-		forceCF     = flag.Bool("force-cloudflare", false, "Force Cloudflare DNS (1.1.1.1) only")
-		noSystemDNS = flag.Bool("no-system-dns", false, "Skip system DNS resolver")
-		verbose     = flag.Bool("v", false, "Verbose output (show which DNS strategy worked)")
+		workers       = flag.Int("workers", NumWorkers, "Number of concurrent DNS workers")
+		bufferSize    = flag.Int("buffer", InputBufferSize, "Input buffer size")
+		dnsTimeout    = flag.Duration("timeout", DNSTimeout, "DNS lookup timeout")
+		forceGoogle   = flag.Bool("force-google", false, "Force Google DNS (8.8.8.8) only")
+		forceCF       = flag.Bool("force-cloudflare", false, "Force Cloudflare DNS (1.1.1.1) only")
+		noSystemDNS   = flag.Bool("no-system-dns", false, "Skip system DNS resolver")
+		verbose       = flag.Bool("v", false, "Verbose output (show which DNS strategy worked)")
+		recordsFlag   = flag.String("records", "", "Comma-separated record types to print alongside each line, e.g. A,AAAA,CNAME,MX,NS,TXT,CAA")
+		protocolsFlag = flag.String("protocols", "udp", "Comma-separated transports to race per lookup: udp,tcp,dot,doh")
+		resolversFlag = flag.String("resolvers", "", "Comma-separated upstream URLs, e.g. udp://1.1.1.1:53,tls://9.9.9.9:853,https://cloudflare-dns.com/dns-query (overrides -protocols' built-in defaults)")
+		cacheTTL      = flag.Duration("cache-ttl", DefaultCacheTTL, "Fallback cache TTL used when a resolver doesn't expose one (authoritative TTLs are preferred when available)")
+		cdnRefresh    = flag.Duration("cdn-refresh", 24*time.Hour, "How often to refresh CDN/cloud IP ranges from provider sources")
+		offline       = flag.Bool("offline", false, "Never fetch CDN/cloud IP ranges over the network; use the disk cache or bundled snapshot as-is")
+		configPath    = flag.String("config", "", "Path to a resolver.yaml policy file declaring primary/fallback resolvers and per-domain routing rules (overrides -protocols and -resolvers; cannot be combined with -force-google or -force-cloudflare)")
+		outputFormat  = flag.String("output", "text", "Output format: text|ndjson|csv")
+		outputFile    = flag.String("output-file", "", "Write output here instead of stdout")
+		syslogAddr    = flag.String("syslog", "", "Also forward each result to this syslog host:port over UDP")
+		ipVersionFlag = flag.String("ip-version", "both", "Address family to resolve: 4, 6, or both (both races A/AAAA Happy-Eyeballs style and returns whichever answers first)")
 	)
 	flag.Parse()
 
+	ipVersion, err := resolver.ParseIPVersion(*ipVersionFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Validate mutually exclusive options
 	if *forceGoogle && *forceCF {
 		fmt.Fprintf(os.Stderr, "Error: Cannot use both -force-google and -force-cloudflare\n")
 		os.Exit(1)
 	}
+	if *configPath != "" && (*forceGoogle || *forceCF) {
+		fmt.Fprintf(os.Stderr, "Error: -config cannot be combined with -force-google or -force-cloudflare\n")
+		os.Exit(1)
+	}
+
+	var resolverPolicy *policy.Policy
+	if *configPath != "" {
+		policyCfg, err := policy.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		resolverPolicy, err = policy.Compile(policyCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// -records is opt-in: an empty flag value disables the extra lookups
+	// entirely so default output is unchanged.
+	var recordTypes []resolver.RecordType
+	if *recordsFlag != "" {
+		var err error
+		recordTypes, err = resolver.ParseRecordTypes(*recordsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Caches deduplicate concurrent lookups for the same key (via
+	// singleflight) and memoize results for their TTL, so a SAN list
+	// full of repeat CDN hostnames doesn't re-resolve and re-PTR the
+	// same names on every occurrence.
+	recordCache := resolver.NewRecordCache(CacheMaxEntries)
+	ipCache := resolver.NewIPCache(CacheMaxEntries)
+	reverseCache := resolver.NewCache[string](CacheMaxEntries)
+
+	recordResolver := resolver.NewCached(RecordServer, *dnsTimeout, recordCache, *cacheTTL)
+
+	// -force-google and -force-cloudflare each query a single nameserver
+	// via the same miekg/dns-backed Resolver/cache the rest of the tool
+	// uses, rather than a bespoke net.Resolver with a custom Dial.
+	googleResolver := resolver.NewCached(GoogleServer, *dnsTimeout, recordCache, *cacheTTL)
+	cfResolver := resolver.NewCached(CloudflareServer, *dnsTimeout, recordCache, *cacheTTL)
+
+	// The system resolver strategy queries whatever nameserver the host
+	// is configured to use (resolv.conf), also over miekg/dns. If that
+	// can't be read (e.g. no resolv.conf on this platform), the strategy
+	// is simply dropped - the upstream race still covers resolution.
+	var systemResolver resolver.Resolver
+	if !*noSystemDNS {
+		systemResolver, err = resolver.NewSystem(*dnsTimeout, recordCache, *cacheTTL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: system resolver unavailable, skipping: %v\n", err)
+		}
+	}
+
+	// Build the list of upstreams to race per lookup. -resolvers, when
+	// given, replaces the -protocols-filtered defaults outright so a user
+	// can point the tool at e.g. an internal resolver behind UDP/53
+	// filtering without fighting the built-in list.
+	upstreams, err := buildUpstreams(*protocolsFlag, *resolversFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// CDN/cloud IP ranges start from the disk cache or bundled snapshot
+	// and refresh from each provider's published source if stale, so
+	// detection stays current without shipping a new binary. ASN lookups
+	// fill in providers the range list doesn't recognize.
+	cdnRegistry := cdn.NewRegistry()
+	if err := cdnRegistry.RefreshIfStale(context.Background(), *cdnRefresh, *offline); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: CDN range refresh failed, using cached/bundled ranges: %v\n", err)
+	}
+	var cdnASN cdn.ASNLookup
+	if !*offline {
+		cdnASN = cdn.NewCymruWhois(*dnsTimeout)
+	}
+
+	var sinkWriter io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		sinkWriter = f
+	}
+	sink, err := output.NewSink(*outputFormat, sinkWriter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *syslogAddr != "" {
+		syslogSink, err := output.NewSyslogSink(*syslogAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sink = output.Multi(sink, syslogSink)
+	}
+	defer sink.Close()
+
+	cfg := dnsConfig{
+		dnsTimeout:     *dnsTimeout,
+		forceGoogle:    *forceGoogle,
+		forceCF:        *forceCF,
+		noSystemDNS:    *noSystemDNS,
+		verbose:        *verbose,
+		recordResolver: recordResolver,
+		recordTypes:    recordTypes,
+		systemResolver: systemResolver,
+		googleResolver: googleResolver,
+		cfResolver:     cfResolver,
+		upstreams:      upstreams,
+		ipCache:        ipCache,
+		reverseCache:   reverseCache,
+		cacheTTL:       *cacheTTL,
+		cdnRegistry:    cdnRegistry,
+		cdnASN:         cdnASN,
+		policy:         resolverPolicy,
+		sink:           sink,
+		ipVersion:      ipVersion,
+	}
+
+	// Regular expression to parse the input format: HOST:PORT [DOMAIN],
+	// where HOST is either a bare IPv4 address or a bracketed IPv6
+	// address (standard net.JoinHostPort notation, e.g. "[::1]:443"),
+	// parsed below with netip.ParseAddrPort.
+	re := regexp.MustCompile(`^(\S+)\s+\[([^\]]+)\]`)
 
-	// Regular expression to parse the input format: IP:PORT [DOMAIN]
-	re := regexp.MustCompile(`^(\d+\.\d+\.\d+\.\d+):(\d+)\s+\[([^\]]+)\]`)
-	
 	// Channels for communication
 	inputChan := make(chan DNSRequest, *bufferSize)
 	outputChan := make(chan DNSResult, *bufferSize)
@@ -102,12 +293,12 @@ func main() {
 	var wg sync.WaitGroup
 	for i := 0; i < *workers; i++ {
 		wg.Add(1)
-		go dnsWorker(inputChan, outputChan, &wg, *dnsTimeout, *forceGoogle, *forceCF, *noSystemDNS, *verbose)
+		go dnsWorker(inputChan, outputChan, &wg, cfg)
 	}
 	
 	// Output worker to print results
 	outputDone := make(chan bool)
-	go outputWorker(outputChan, outputDone)
+	go outputWorker(outputChan, cfg.sink, outputDone)
 	
 	// Read input asynchronously
 	scanner := bufio.NewScanner(os.Stdin)
@@ -126,42 +317,46 @@ func main() {
 			
 			// Parse the input line
 			matches := re.FindStringSubmatch(line)
-			if len(matches) != 4 {
+			var (
+				hostport netip.AddrPort
+				err      error
+			)
+			if len(matches) == 3 {
+				hostport, err = netip.ParseAddrPort(matches[1])
+			}
+			if len(matches) != 3 || err != nil {
 				// If line doesn't match expected format, queue it for printing
+				malformed := DNSResult{line: line, shouldPrint: true, status: "MALFORMED", timestamp: time.Now()}
 				select {
-				case outputChan <- DNSResult{line: line, shouldPrint: true, status: "MALFORMED", resolvedIPs: nil}:
+				case outputChan <- malformed:
 				case <-time.After(time.Second):
 					// If output buffer is full, print directly to avoid blocking
-					fmt.Printf("%s MALFORMED\n", line)
+					cfg.sink.Write(malformed.toRecord())
 				}
 				continue
 			}
-			
-			expectedIP := matches[1]
-			domain := matches[3]
-			
+
+			domain := matches[2]
+
 			// Send to workers for processing
 			request := DNSRequest{
 				line:       line,
-				expectedIP: expectedIP,
+				expectedIP: hostport.Addr(),
+				port:       strconv.Itoa(int(hostport.Port())),
 				domain:     domain,
 			}
-			
+
 			select {
 			case inputChan <- request:
 				inputCount++
 			case <-time.After(time.Second):
 				// If input buffer is full, process inline to avoid blocking
-				result := processDNSRequest(request, *dnsTimeout, *forceGoogle, *forceCF, *noSystemDNS, *verbose)
+				result := processDNSRequest(request, cfg)
 				select {
 				case outputChan <- result:
 				case <-time.After(time.Second):
 					if result.shouldPrint {
-						if len(result.resolvedIPs) > 0 {
-							fmt.Printf("%s %s %s\n", result.line, result.status, strings.Join(result.resolvedIPs, ","))
-						} else {
-							fmt.Printf("%s %s\n", result.line, result.status)
-						}
+						cfg.sink.Write(result.toRecord())
 					}
 				}
 			}
@@ -175,248 +370,338 @@ func main() {
 	// Wait for all workers to finish processing
 	wg.Wait()
 	close(outputChan)
-	
+
 	// Wait for output worker to finish
 	<-outputDone
+
+	if *verbose {
+		printCacheStats("records", recordCache)
+		printCacheStats("ip", ipCache)
+		printCacheStats("ptr", reverseCache)
+	}
+}
+
+func printCacheStats[V any](name string, cache *resolver.Cache[V]) {
+	hits, misses := cache.Stats()
+	fmt.Fprintf(os.Stderr, "[cache:%s] hits=%d misses=%d\n", name, hits, misses)
 }
 
-func dnsWorker(inputChan <-chan DNSRequest, outputChan chan<- DNSResult, wg *sync.WaitGroup, dnsTimeout time.Duration, forceGoogle, forceCF, noSystemDNS, verbose bool) {
+func dnsWorker(inputChan <-chan DNSRequest, outputChan chan<- DNSResult, wg *sync.WaitGroup, cfg dnsConfig) {
 	defer wg.Done()
-	
+
 	for request := range inputChan {
-		result := processDNSRequest(request, dnsTimeout, forceGoogle, forceCF, noSystemDNS, verbose)
-		
+		result := processDNSRequest(request, cfg)
+
 		select {
 		case outputChan <- result:
 		case <-time.After(time.Second):
 			// If output buffer is full, print directly
 			if result.shouldPrint {
-				if len(result.resolvedIPs) > 0 {
-					fmt.Printf("%s %s %s\n", result.line, result.status, strings.Join(result.resolvedIPs, ","))
-				} else {
-					fmt.Printf("%s %s\n", result.line, result.status)
-				}
+				cfg.sink.Write(result.toRecord())
 			}
 		}
 	}
 }
 
-func processDNSRequest(request DNSRequest, dnsTimeout time.Duration, forceGoogle, forceCF, noSystemDNS, verbose bool) DNSResult {
+func processDNSRequest(request DNSRequest, cfg dnsConfig) DNSResult {
+	start := time.Now()
+
 	// Create context with configurable timeout for DNS lookup
-	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout*3) // 3x timeout for retries
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.dnsTimeout*3) // 3x timeout for retries
 	defer cancel()
-	
-	// Build DNS resolution strategies based on flags
-	var strategies []func(context.Context, string) ([]net.IPAddr, error)
-	
-	if forceGoogle {
+
+	// Build DNS resolution strategies based on flags, each carrying its
+	// own name so strategyUsed/-v is looked up by name rather than by
+	// position - a strategy dropping out (e.g. -no-system-dns) or the
+	// set changing shape can't silently desync an index into the wrong
+	// label.
+	//
+	// systemStrategy, when set, is tried first and alone: it's the host's
+	// own (possibly split-horizon/internal) view of the world, and a
+	// trustworthy SAN triage verdict shouldn't flip depending on whether
+	// a public upstream happened to answer faster on a given run. Only
+	// once it fails (or isn't configured) do we fall through to racing
+	// the remaining strategies against each other.
+	var systemStrategy func(context.Context, string) ([]net.IPAddr, error)
+	var strategies []namedStrategy
+
+	if cfg.policy != nil {
+		// -config opts into the richer primary/fallback/per-domain routing
+		// policy, replacing the force-google/force-cloudflare/-resolvers
+		// strategies entirely.
+		strategies = []namedStrategy{{
+			name: "policy",
+			fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+				return policy.Resolve(ctx, cfg.policy, domain, cfg.dnsTimeout, cfg.ipCache, cfg.cacheTTL, cfg.ipVersion)
+			},
+		}}
+	} else if cfg.forceGoogle {
 		// Only use Google DNS
-		strategies = []func(context.Context, string) ([]net.IPAddr, error){
-			func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-				resolver := &net.Resolver{
-					PreferGo: true,
-					Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-						d := net.Dialer{Timeout: dnsTimeout}
-						return d.DialContext(ctx, network, "8.8.8.8:53")
-					},
-				}
-				return resolver.LookupIPAddr(ctx, domain)
+		strategies = []namedStrategy{{
+			name: "google",
+			fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+				return resolver.LookupIPAddr(ctx, cfg.googleResolver, domain, cfg.ipVersion)
 			},
-		}
-	} else if forceCF {
+		}}
+	} else if cfg.forceCF {
 		// Only use Cloudflare DNS
-		strategies = []func(context.Context, string) ([]net.IPAddr, error){
-			func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-				resolver := &net.Resolver{
-					PreferGo: true,
-					Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-						d := net.Dialer{Timeout: dnsTimeout}
-						return d.DialContext(ctx, network, "1.1.1.1:53")
-					},
-				}
-				return resolver.LookupIPAddr(ctx, domain)
+		strategies = []namedStrategy{{
+			name: "cloudflare",
+			fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+				return resolver.LookupIPAddr(ctx, cfg.cfResolver, domain, cfg.ipVersion)
 			},
-		}
+		}}
 	} else {
-		// Multiple DNS resolution strategies to handle caching/config issues
-		if !noSystemDNS {
-			// Strategy 1: System default resolver
-			strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-				return net.DefaultResolver.LookupIPAddr(ctx, domain)
-			})
-			
-			// Strategy 2: Force Go's built-in resolver (bypasses system DNS)
-			strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-				resolver := &net.Resolver{PreferGo: true}
-				return resolver.LookupIPAddr(ctx, domain)
-			})
-		}
-		
-		// Strategy 3: Google DNS (8.8.8.8)
-		strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-			resolver := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					d := net.Dialer{Timeout: dnsTimeout}
-					return d.DialContext(ctx, network, "8.8.8.8:53")
-				},
-			}
-			return resolver.LookupIPAddr(ctx, domain)
-		})
-		
-		// Strategy 4: Cloudflare DNS (1.1.1.1)
-		strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-			resolver := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					d := net.Dialer{Timeout: dnsTimeout}
-					return d.DialContext(ctx, network, "1.1.1.1:53")
-				},
-			}
-			return resolver.LookupIPAddr(ctx, domain)
-		})
-		
-		// Strategy 5: Quad9 DNS (9.9.9.9) - security-focused DNS
-		strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-			resolver := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					d := net.Dialer{Timeout: dnsTimeout}
-					return d.DialContext(ctx, network, "9.9.9.9:53")
-				},
-			}
-			return resolver.LookupIPAddr(ctx, domain)
-		})
-		
-		// Strategy 6: OpenDNS (208.67.222.222)
-		strategies = append(strategies, func(ctx context.Context, domain string) ([]net.IPAddr, error) {
-			resolver := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					d := net.Dialer{Timeout: dnsTimeout}
-					return d.DialContext(ctx, network, "208.67.222.222:53")
-				},
+		// The system resolver is tried first, deterministically, below -
+		// it isn't part of the race.
+		if cfg.systemResolver != nil {
+			systemStrategy = func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+				return resolver.LookupIPAddr(ctx, cfg.systemResolver, domain, cfg.ipVersion)
 			}
-			return resolver.LookupIPAddr(ctx, domain)
+		}
+
+		// Race the configured upstreams (-resolvers, or the
+		// -protocols-filtered public defaults) over UDP/TCP/DoT/DoH,
+		// deduplicated and cached across workers. This is the only tier
+		// that's actually raced: the public resolvers it covers carry no
+		// precedence relative to one another, unlike the system resolver.
+		strategies = append(strategies, namedStrategy{
+			name: "upstreams",
+			fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+				return resolver.RaceIPAddr(ctx, cfg.upstreams, domain, cfg.dnsTimeout, cfg.ipCache, cfg.cacheTTL, cfg.ipVersion)
+			},
 		})
 	}
-	
+
+	// Try the system resolver first and alone; only race the remaining
+	// strategies (public upstreams, or the single policy/google/cloudflare
+	// strategy) against each other if it fails or isn't configured.
 	var ips []net.IPAddr
+	var strategyUsed string
 	var err error
-	var successfulStrategy int = -1
-	
-	// Try each strategy until one succeeds
-	for i, strategy := range strategies {
-		ips, err = strategy(ctx, request.domain)
+	if systemStrategy != nil {
+		ips, err = systemStrategy(ctx, request.domain)
 		if err == nil && len(ips) > 0 {
-			successfulStrategy = i
-			break
+			strategyUsed = "system"
 		}
-		// Small delay between attempts to avoid overwhelming DNS servers
-		time.Sleep(50 * time.Millisecond)
 	}
-	
+	if strategyUsed == "" {
+		ips, strategyUsed, err = raceStrategies(ctx, request.domain, strategies)
+	}
+
 	// If all strategies failed, try one more time with LookupHost as fallback
 	if err != nil {
 		var hosts []string
 		hosts, err = net.LookupHost(request.domain)
 		if err == nil && len(hosts) > 0 {
-			// Convert string IPs to IPAddr
+			// Convert string IPs to IPAddr, filtered to the requested family
 			for _, host := range hosts {
-				if ip := net.ParseIP(host); ip != nil {
+				if ip := net.ParseIP(host); ip != nil && cfg.ipVersion.Accepts(ip) {
 					ips = append(ips, net.IPAddr{IP: ip})
 				}
 			}
-			successfulStrategy = len(strategies) // Indicate fallback was used
+			if len(ips) > 0 {
+				strategyUsed = "fallback"
+			}
 		}
 	}
-	
+
+	// When -records is set, look up the requested record types alongside
+	// the IP-match logic below so triage doesn't need a second pass.
+	var recordInfo string
+	if len(cfg.recordTypes) > 0 {
+		recordInfo = resolver.FormatRecords(resolver.LookupAll(ctx, cfg.recordResolver, request.domain, cfg.recordTypes), cfg.recordTypes)
+	}
+
 	if err != nil || len(ips) == 0 {
 		// All DNS resolution attempts failed
 		return DNSResult{
-			line:        request.line,
-			shouldPrint: true,
-			status:      "DNS_FAILURE",
-			resolvedIPs: nil,
+			line:         request.line,
+			shouldPrint:  true,
+			status:       "DNS_FAILURE",
+			expectedIP:   request.expectedIP.String(),
+			port:         request.port,
+			domain:       request.domain,
+			recordInfo:   recordInfo,
+			strategyUsed: strategyUsed,
+			elapsedMS:    time.Since(start).Milliseconds(),
+			timestamp:    start,
 		}
 	}
-	
-	// Convert resolved IPs to strings and check for matches
+
+	// Convert resolved IPs to strings and check for matches. Comparing
+	// via netip.Addr (rather than the raw strings) means an IPv6 answer
+	// that net.IP.String() renders differently than the input's own
+	// notation (e.g. an IPv4-mapped form) still matches correctly.
 	var resolvedIPStrings []string
 	var foundMatch bool
-	
+
 	for _, ip := range ips {
 		ipStr := ip.IP.String()
 		resolvedIPStrings = append(resolvedIPStrings, ipStr)
-		if ipStr == request.expectedIP {
+		if addr, ok := netip.AddrFromSlice(ip.IP); ok && addr.Unmap() == request.expectedIP.Unmap() {
 			foundMatch = true
 		}
 	}
-	
+
 	if foundMatch {
-		// Found match, don't print
+		resolved := make([]output.ResolvedIP, len(resolvedIPStrings))
+		for i, ipStr := range resolvedIPStrings {
+			resolved[i] = output.ResolvedIP{IP: ipStr}
+		}
+		// Found match, don't print unless -records was requested, in
+		// which case the user still wants the record dump for this line
 		return DNSResult{
-			line:        request.line,
-			shouldPrint: false,
-			status:      "MATCH",
-			resolvedIPs: resolvedIPStrings,
+			line:         request.line,
+			shouldPrint:  recordInfo != "",
+			status:       "MATCH",
+			expectedIP:   request.expectedIP.String(),
+			port:         request.port,
+			domain:       request.domain,
+			resolved:     resolved,
+			recordInfo:   recordInfo,
+			strategyUsed: strategyUsed,
+			elapsedMS:    time.Since(start).Milliseconds(),
+			timestamp:    start,
 		}
 	}
-	
+
 	// Expected IP was NOT found - determine if it's CDN or regular mismatch
-	cdnProvider := detectCDN(resolvedIPStrings)
 	status := "IP_MISMATCH"
-	if cdnProvider != "" {
-		status = fmt.Sprintf("CDN_MISMATCH_%s", strings.ToUpper(cdnProvider))
+	if info, ok := cdn.Detect(ctx, cfg.cdnRegistry.Ranges(), cfg.cdnASN, resolvedIPStrings); ok {
+		status = fmt.Sprintf("CDN_MISMATCH_%s", strings.ToUpper(info.Tag()))
 	}
-	
+
 	// Add strategy indicator for debugging if verbose mode is enabled
-	if verbose {
-		strategyNames := []string{"system", "go-builtin", "google", "cloudflare", "quad9", "opendns", "fallback"}
-		if successfulStrategy >= 0 && successfulStrategy < len(strategyNames) {
-			status = fmt.Sprintf("%s_VIA_%s", status, strings.ToUpper(strategyNames[successfulStrategy]))
-		}
+	if cfg.verbose && strategyUsed != "" {
+		status = fmt.Sprintf("%s_VIA_%s", status, strings.ToUpper(strategyUsed))
 	}
-	
+
 	// Perform reverse DNS lookups for better intelligence
-	formattedIPs := performReverseLookups(net.DefaultResolver, ctx, resolvedIPStrings)
-	
+	resolved := performReverseLookups(net.DefaultResolver, ctx, resolvedIPStrings, cfg)
+
 	return DNSResult{
-		line:        request.line,
-		shouldPrint: true,
-		status:      status,
-		resolvedIPs: formattedIPs,
+		line:         request.line,
+		shouldPrint:  true,
+		status:       status,
+		expectedIP:   request.expectedIP.String(),
+		port:         request.port,
+		domain:       request.domain,
+		resolved:     resolved,
+		recordInfo:   recordInfo,
+		strategyUsed: strategyUsed,
+		elapsedMS:    time.Since(start).Milliseconds(),
+		timestamp:    start,
+	}
+}
+
+// namedStrategy pairs a DNS resolution strategy with the name it's
+// reported as in strategyUsed/-v, so that name travels with the
+// strategy itself rather than being derived from its position in a
+// slice.
+type namedStrategy struct {
+	name string
+	fn   func(context.Context, string) ([]net.IPAddr, error)
+}
+
+// raceStrategies runs every strategy concurrently against domain and
+// returns the first successful answer along with the name of whichever
+// strategy produced it, cancelling the rest via raceCtx - the same
+// treatment resolver.RaceIPAddr gives individual upstreams, applied one
+// level up to the strategies themselves. The system resolver is
+// deliberately excluded from this race (see processDNSRequest): it's
+// tried first and alone so a trustworthy verdict doesn't depend on which
+// strategy happens to answer fastest. Returns an empty name if every
+// strategy failed.
+func raceStrategies(ctx context.Context, domain string, strategies []namedStrategy) ([]net.IPAddr, string, error) {
+	if len(strategies) == 0 {
+		return nil, "", fmt.Errorf("no resolution strategies configured")
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		name string
+		ips  []net.IPAddr
+		err  error
+	}
+	results := make(chan result, len(strategies))
+	for _, s := range strategies {
+		go func(s namedStrategy) {
+			ips, err := s.fn(raceCtx, domain)
+			results <- result{name: s.name, ips: ips, err: err}
+		}(s)
+	}
+
+	var lastErr error
+	for i := 0; i < len(strategies); i++ {
+		r := <-results
+		if r.err == nil && len(r.ips) > 0 {
+			return r.ips, r.name, nil
+		}
+		if r.err != nil {
+			lastErr = r.err
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no strategy returned any addresses for %s", domain)
 	}
+	return nil, "", lastErr
 }
 
-func performReverseLookups(resolver *net.Resolver, ctx context.Context, ips []string) []string {
+// performReverseLookups resolves each IP's PTR record and CDN/cloud
+// classification (by range only - not the ASN whois fallback, so a
+// batch of resolved IPs doesn't trigger a whois query per address;
+// cfg.cdnASN is still consulted once for the mismatch status itself).
+func performReverseLookups(netResolver *net.Resolver, ctx context.Context, ips []string, cfg dnsConfig) []output.ResolvedIP {
 	type reverseResult struct {
 		ip       string
 		hostname string
 	}
-	
+
 	// Channel to collect reverse lookup results
 	results := make(chan reverseResult, len(ips))
-	
-	// Perform reverse lookups concurrently
+
+	// Perform reverse lookups concurrently, deduplicated and cached so
+	// the same IP (a CDN edge, say) isn't re-PTR'd by every worker that
+	// sees it.
 	for _, ip := range ips {
 		go func(ipAddr string) {
-			// Create a shorter timeout context for reverse lookups
-			reverseCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-			defer cancel()
-			
-			hostnames, err := resolver.LookupAddr(reverseCtx, ipAddr)
-			if err != nil || len(hostnames) == 0 {
-				// No reverse DNS or lookup failed
+			// fetch applies its own short timeout to the actual PTR query
+			// rather than relying on the caller's context for that bound:
+			// when routed through cfg.reverseCache, this fetch may be
+			// shared with unrelated callers via singleflight, so it can't
+			// be tied to any single one of them being cancelled.
+			fetch := func(fetchCtx context.Context) (string, time.Duration, error) {
+				fetchCtx, cancel := context.WithTimeout(fetchCtx, 2*time.Second)
+				defer cancel()
+
+				hostnames, err := netResolver.LookupAddr(fetchCtx, ipAddr)
+				if err != nil || len(hostnames) == 0 {
+					return "", 0, fmt.Errorf("no PTR for %s", ipAddr)
+				}
+				return strings.TrimSuffix(hostnames[0], "."), 0, nil
+			}
+
+			var (
+				hostname string
+				err      error
+			)
+			if cfg.reverseCache != nil {
+				key := resolver.CacheKey{Domain: ipAddr, QType: "PTR", Resolver: "system"}
+				hostname, err = cfg.reverseCache.Lookup(ctx, key, cfg.cacheTTL, fetch)
+			} else {
+				hostname, _, err = fetch(ctx)
+			}
+			if err != nil {
 				results <- reverseResult{ip: ipAddr, hostname: ""}
 			} else {
-				// Use the first hostname, remove trailing dot if present
-				hostname := strings.TrimSuffix(hostnames[0], ".")
 				results <- reverseResult{ip: ipAddr, hostname: hostname}
 			}
 		}(ip)
 	}
-	
+
 	// Collect results
 	reverseMap := make(map[string]string)
 	for i := 0; i < len(ips); i++ {
@@ -428,51 +713,74 @@ func performReverseLookups(resolver *net.Resolver, ctx context.Context, ips []st
 			break
 		}
 	}
-	
-	// Format results as "IP[hostname]" or "IP"
-	var formattedIPs []string
+
+	resolved := make([]output.ResolvedIP, 0, len(ips))
 	for _, ip := range ips {
-		if hostname, exists := reverseMap[ip]; exists && hostname != "" {
-			formattedIPs = append(formattedIPs, fmt.Sprintf("%s[%s]", ip, hostname))
-		} else {
-			formattedIPs = append(formattedIPs, ip)
+		entry := output.ResolvedIP{IP: ip, PTR: reverseMap[ip]}
+		if info, ok := cdn.Detect(ctx, cfg.cdnRegistry.Ranges(), nil, []string{ip}); ok {
+			entry.CDN = info.Provider
+			entry.ASN = info.ASN
 		}
+		resolved = append(resolved, entry)
 	}
-	
-	return formattedIPs
+	return resolved
 }
 
-func detectCDN(ips []string) string {
-	for _, ip := range ips {
-		parsedIP := net.ParseIP(ip)
-		if parsedIP == nil {
-			continue
-		}
-		
-		for provider, cidrs := range cdnProviders {
-			for _, cidr := range cidrs {
-				_, ipnet, err := net.ParseCIDR(cidr)
-				if err != nil {
-					continue
-				}
-				if ipnet.Contains(parsedIP) {
-					return provider
-				}
-			}
+// defaultUpstreams are the public resolvers used when -resolvers isn't
+// given, keyed by the protocol they're reached over.
+var defaultUpstreams = map[resolver.Protocol][]resolver.Upstream{
+	resolver.ProtoUDP: {
+		{Protocol: resolver.ProtoUDP, Address: "8.8.8.8:53"},
+		{Protocol: resolver.ProtoUDP, Address: "1.1.1.1:53"},
+		{Protocol: resolver.ProtoUDP, Address: "9.9.9.9:53"},
+		{Protocol: resolver.ProtoUDP, Address: "208.67.222.222:53"},
+	},
+	resolver.ProtoTCP: {
+		{Protocol: resolver.ProtoTCP, Address: "8.8.8.8:53"},
+		{Protocol: resolver.ProtoTCP, Address: "1.1.1.1:53"},
+		{Protocol: resolver.ProtoTCP, Address: "9.9.9.9:53"},
+	},
+	resolver.ProtoDoT: {
+		{Protocol: resolver.ProtoDoT, Address: "8.8.8.8:853"},
+		{Protocol: resolver.ProtoDoT, Address: "1.1.1.1:853"},
+		{Protocol: resolver.ProtoDoT, Address: "9.9.9.9:853"},
+	},
+	resolver.ProtoDoH: {
+		{Protocol: resolver.ProtoDoH, Address: "https://dns.google/dns-query"},
+		{Protocol: resolver.ProtoDoH, Address: "https://cloudflare-dns.com/dns-query"},
+		{Protocol: resolver.ProtoDoH, Address: "https://dns.quad9.net/dns-query"},
+	},
+}
+
+// buildUpstreams resolves -resolvers and -protocols into the final list
+// of upstreams to race per lookup. An explicit -resolvers list wins
+// outright; otherwise the built-in defaults are filtered by -protocols.
+func buildUpstreams(protocolsFlag, resolversFlag string) ([]resolver.Upstream, error) {
+	if resolversFlag != "" {
+		return resolver.ParseUpstreams(resolversFlag)
+	}
+
+	protocols, err := resolver.ParseProtocols(protocolsFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	var upstreams []resolver.Upstream
+	for _, proto := range []resolver.Protocol{resolver.ProtoUDP, resolver.ProtoTCP, resolver.ProtoDoT, resolver.ProtoDoH} {
+		if protocols[proto] {
+			upstreams = append(upstreams, defaultUpstreams[proto]...)
 		}
 	}
-	return ""
+	return upstreams, nil
 }
 
-func outputWorker(outputChan <-chan DNSResult, done chan<- bool) {
+func outputWorker(outputChan <-chan DNSResult, sink output.Sink, done chan<- bool) {
 	defer func() { done <- true }()
-	
+
 	for result := range outputChan {
 		if result.shouldPrint {
-			if len(result.resolvedIPs) > 0 {
-				fmt.Printf("%s %s %s\n", result.line, result.status, strings.Join(result.resolvedIPs, ","))
-			} else {
-				fmt.Printf("%s %s\n", result.line, result.status)
+			if err := sink.Write(result.toRecord()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
 			}
 		}
 	}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestRaceStrategiesReturnsWinningStrategyName(t *testing.T) {
+	succeed := func(name string) namedStrategy {
+		return namedStrategy{name: name, fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP("1.2.3.4")}}, nil
+		}}
+	}
+	fail := func(name string) namedStrategy {
+		return namedStrategy{name: name, fn: func(ctx context.Context, domain string) ([]net.IPAddr, error) {
+			return nil, errors.New(name + " failed")
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		strategies []namedStrategy
+		want       string
+	}{
+		{
+			name:       "single strategy succeeds",
+			strategies: []namedStrategy{succeed("upstreams")},
+			want:       "upstreams",
+		},
+		{
+			// Regression test for a bug where the winning strategy's
+			// reported name was derived from its position in a separate
+			// slice that could drift out of sync with the strategies
+			// actually raced - e.g. once "system" is tried outside the
+			// race (see processDNSRequest), the race itself only ever
+			// contains "upstreams", and a successful upstream result
+			// must be reported as "upstreams", never "fallback".
+			name:       "only non-first strategy succeeds",
+			strategies: []namedStrategy{fail("a"), succeed("upstreams"), fail("c")},
+			want:       "upstreams",
+		},
+		{
+			name:       "every strategy fails",
+			strategies: []namedStrategy{fail("a"), fail("b")},
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, got, err := raceStrategies(context.Background(), "example.com", tt.strategies)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("raceStrategies() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("raceStrategies() error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("raceStrategies() strategy name = %q, want %q", got, tt.want)
+			}
+			if len(ips) == 0 {
+				t.Errorf("raceStrategies() returned no IPs for a winning strategy")
+			}
+		})
+	}
+}